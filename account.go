@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAccountPendingDeletion = newAppError("ACCOUNT_PENDING_DELETION", http.StatusForbidden, "账户已注销，可在等待期内恢复", "This account is scheduled for deletion and can be restored")
+	ErrRestoreExpired         = newAppError("RESTORE_WINDOW_EXPIRED", http.StatusGone, "已超过恢复期限，账户无法恢复", "The restore window has expired")
+)
+
+// 恢复账户请求结构体
+type RestoreAccountRequest struct {
+	UserName string `json:"userName" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// 账户注销服务。注销时先软删除用户，等待期结束后清除该用户在所有表中的数据
+type AccountService struct {
+	db    *gorm.DB
+	grace time.Duration
+	now   func() time.Time
+}
+
+func NewAccountService(db *gorm.DB, cfg AccountConfig) *AccountService {
+	return &AccountService{db: db, grace: cfg.DeletionGrace, now: time.Now}
+}
+
+// 注销账户，返回数据将被清除的时间
+func (s *AccountService) ScheduleDeletion(userID int) (time.Time, error) {
+	now := s.now()
+	res := s.db.Model(&User{}).Where("id = ?", userID).Update("deleted_at", now)
+	if res.Error != nil {
+		return time.Time{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return time.Time{}, ErrUserNotFound
+	}
+	return now.Add(s.grace), nil
+}
+
+// 在等待期内恢复已注销的账户
+func (s *AccountService) Restore(userName, password string) (*User, error) {
+	var user User
+	err := s.db.Unscoped().Where("user_name = ? AND deleted_at IS NOT NULL AND purged_at IS NULL", userName).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if !checkPassword(password, user.Password) {
+		return nil, ErrInvalidCredentials
+	}
+	if s.now().After(user.DeletedAt.Time.Add(s.grace)) {
+		return nil, ErrRestoreExpired
+	}
+	if err := s.db.Unscoped().Model(&User{}).Where("id = ?", user.ID).Update("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 等待期已结束、尚未清除数据的账户
+func (s *AccountService) Due() ([]int, error) {
+	var ids []int
+	err := s.db.Unscoped().Model(&User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at <= ? AND purged_at IS NULL", s.now().Add(-s.grace)).
+		Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// 清除用户的全部数据：删除其发布的内容、点赞、好友关系、祝福、聊天记录和头像，
+// 保留匿名化的用户记录，使举报等引用仍然有效。返回需要删除的头像文件名
+func (s *AccountService) Purge(userID int) ([]string, error) {
+	var avatars []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Unscoped().First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if !user.DeletedAt.Valid || user.PurgedAt != nil {
+			return nil
+		}
+
+		// 自己的说说及其下的点赞、评论和评论点赞
+		postIDs := tx.Unscoped().Model(&Post{}).Select("id").Where("user_id = ?", userID)
+		postCommentIDs := tx.Unscoped().Model(&Comment{}).Select("id").Where("post_id IN (?)", postIDs)
+		if err := tx.Where("comment_id IN (?)", postCommentIDs).Delete(&CommentLike{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("post_id IN (?)", postIDs).Delete(&Comment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN (?)", postIDs).Delete(&Like{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN (?) OR user_id = ?", postIDs, userID).Delete(&PostAudience{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("post_id IN (?)", postIDs).Delete(&PostEdit{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&Post{}).Error; err != nil {
+			return err
+		}
+
+		// 给别人的点赞，同时扣减点赞数
+		likedPosts := tx.Model(&Like{}).Select("post_id").Where("user_id = ?", userID)
+		if err := tx.Unscoped().Model(&Post{}).Where("id IN (?)", likedPosts).
+			Update("like_count", gorm.Expr("like_count - 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&Like{}).Error; err != nil {
+			return err
+		}
+		likedComments := tx.Model(&CommentLike{}).Select("comment_id").Where("user_id = ?", userID)
+		if err := tx.Unscoped().Model(&Comment{}).Where("id IN (?)", likedComments).
+			Update("like_count", gorm.Expr("like_count - 1")).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&CommentLike{}).Error; err != nil {
+			return err
+		}
+
+		// 在别人说说下的评论
+		commentIDs := tx.Unscoped().Model(&Comment{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("comment_id IN (?)", commentIDs).Delete(&CommentLike{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&Comment{}).Error; err != nil {
+			return err
+		}
+
+		for _, q := range []struct {
+			model interface{}
+			where string
+		}{
+			{&FriendRequest{}, "from_id = ? OR to_id = ?"},
+			{&FriendRelationship{}, "user_id = ? OR friend_id = ?"},
+			{&Blessing{}, "sender_id = ? OR receiver_id = ?"},
+			{&ChatMessage{}, "sender_id = ? OR receiver_id = ?"},
+		} {
+			if err := tx.Where(q.where, userID, userID).Delete(q.model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&ProfileVisibility{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&UserInterest{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&Avatar{}).Where("user_id = ?", userID).Pluck("filename", &avatars).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&Avatar{}).Error; err != nil {
+			return err
+		}
+
+		// 用户名包含注册时不允许的字符，不会与新用户冲突；原用户名和邮箱可以重新注册
+		return tx.Unscoped().Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"user_name":       fmt.Sprintf("deleted#%d", userID),
+			"password":        "",
+			"nick_name":       "",
+			"age":             0,
+			"birthday":        nil,
+			"gender":          "",
+			"status":          "",
+			"email":           nil,
+			"ban_reason":      "",
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"purged_at":       s.now(),
+		}).Error
+	})
+	return avatars, err
+}
+
+// 清除单个账户的数据库记录、头像和导出文件以及 Redis 中的离线消息
+func (a *App) purgeAccount(ctx context.Context, userID int) error {
+	avatars, err := a.accounts.Purge(userID)
+	if err != nil {
+		return err
+	}
+	if err := a.exports.RemoveUser(userID); err != nil {
+		return err
+	}
+	for _, name := range avatars {
+		if err := os.Remove(filepath.Join(a.cfg.Upload.Dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("删除用户 %d 的头像文件失败: %v", userID, err)
+		}
+	}
+	return a.rdb.Del(ctx, offlineKeyPrefix+strconv.Itoa(userID)).Err()
+}
+
+// 清除所有到期的账户，返回清除的数量
+func (a *App) PurgeDueAccounts(ctx context.Context) (int, error) {
+	ids, err := a.accounts.Due()
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	purged := 0
+	for _, id := range ids {
+		if err := a.purgeAccount(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("清除用户 %d: %w", id, err))
+			continue
+		}
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+// 后台定期清除到期账户，ctx 结束后退出
+func (a *App) runAccountPurger(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Account.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := a.PurgeDueAccounts(ctx); err != nil {
+			log.Printf("清除注销账户失败: %v", err)
+		} else if n > 0 {
+			log.Printf("已清除 %d 个注销账户", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 执行 purge-accounts 子命令：立即清除所有到期的注销账户
+func runPurgeAccounts(ctx context.Context, a *App, out io.Writer) error {
+	n, err := a.PurgeDueAccounts(ctx)
+	fmt.Fprintf(out, "已清除 %d 个注销账户\n", n)
+	return err
+}
+
+// 注销账户：立即退出所有设备，等待期结束后永久清除数据
+func (a *App) deleteAccountHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	purgeAt, err := a.accounts.ScheduleDeletion(userID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	if err := a.auth.LogoutAll(c.Request.Context(), userID); err != nil {
+		log.Printf("吊销用户 %d 的会话失败: %v", userID, err)
+	}
+	ResponseOK(c, gin.H{"purgeAt": purgeAt}, "账户已注销，等待期内可以恢复")
+}
+
+// 恢复已注销的账户，恢复后需要重新登录
+func (a *App) restoreAccountHandler(c *gin.Context) {
+	var req RestoreAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	if wait, err := a.lockout.Locked(ctx, req.UserName); err != nil {
+		log.Printf("查询账户锁定状态失败: %v", err)
+	} else if wait > 0 {
+		responseRetryAfter(c, ErrAccountLocked, wait)
+		return
+	}
+	if _, err := a.accounts.Restore(req.UserName, req.Password); err != nil {
+		if errors.Is(err, ErrInvalidCredentials) {
+			if _, lockErr := a.lockout.Fail(ctx, req.UserName); lockErr != nil {
+				log.Printf("记录登录失败次数失败: %v", lockErr)
+			}
+		}
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "账户已恢复，请重新登录")
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 将注销服务的时间推进到等待期之后
+func (ts *testServer) passGracePeriod() {
+	later := time.Now().Add(ts.cfg.Account.DeletionGrace + time.Minute)
+	ts.app.accounts.now = func() time.Time { return later }
+}
+
+func (ts *testServer) uploadAvatar(token string) {
+	ts.t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("image", "me.png")
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	part.Write([]byte("\x89PNG\r\n\x1a\nfake"))
+	mw.Close()
+	req, _ := http.NewRequest(http.MethodPost, ts.server.URL+"/avatar/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	expectOK(ts.t, ts.send(req, token))
+}
+
+func TestDeleteAndRestoreAccount(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	ts.befriend(alice, bob, bobID)
+	ts.createPost(alice, "新年快乐")
+
+	var deleted struct {
+		PurgeAt time.Time `json:"purgeAt"`
+	}
+	mustDecode(t, ts.do(http.MethodDelete, "/account", alice, nil), &deleted)
+	if d := time.Until(deleted.PurgeAt); d < ts.cfg.Account.DeletionGrace-time.Minute || d > ts.cfg.Account.DeletionGrace {
+		t.Fatalf("清除时间不符: %v", deleted.PurgeAt)
+	}
+
+	// 等待期内其他用户看不到已注销用户
+	var friends struct {
+		Friends []FriendInfo `json:"friends"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/friend/list", bob, nil), &friends)
+	if len(friends.Friends) != 0 {
+		t.Fatalf("好友列表不应包含已注销用户: %+v", friends)
+	}
+	posts := ts.listPosts(bob, "/posts").Posts
+	if len(posts) != 0 {
+		t.Fatalf("不应显示已注销用户的说说: %+v", posts)
+	}
+
+	expectError(t, ts.do(http.MethodPost, "/account/restore", "", gin.H{"userName": "alice", "password": "wrong"}), "INVALID_CREDENTIALS")
+	expectError(t, ts.do(http.MethodPost, "/account/restore", "", gin.H{"userName": "bob", "password": "password123"}), "INVALID_CREDENTIALS")
+	expectOK(t, ts.do(http.MethodPost, "/account/restore", "", gin.H{"userName": "alice", "password": "password123"}))
+	ts.login("alice")
+	posts = ts.listPosts(bob, "/posts").Posts
+	if len(posts) != 1 {
+		t.Fatalf("恢复后应重新显示说说: %+v", posts)
+	}
+
+	// 超过等待期后无法恢复
+	expectOK(t, ts.do(http.MethodDelete, "/account", ts.login("alice").AccessToken, nil))
+	ts.passGracePeriod()
+	expectError(t, ts.do(http.MethodPost, "/account/restore", "", gin.H{"userName": "alice", "password": "password123"}), "RESTORE_WINDOW_EXPIRED")
+}
+
+func TestPurgeAccount(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	ts.befriend(alice, bob, bobID)
+	expectOK(t, ts.do(http.MethodPut, "/profile", alice, gin.H{"nickName": "小爱", "email": "alice@example.com"}))
+	ts.uploadAvatar(alice)
+	expectOK(t, ts.do(http.MethodPut, "/profile/privacy", alice, gin.H{"age": "private"}))
+
+	// alice 的说说，bob 点赞和评论
+	alicePost := ts.createPost(alice, "alice 的说说")
+	expectOK(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(alicePost)+"/like", bob, nil))
+	expectOK(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(alicePost)+"/comments", bob, gin.H{"content": "好"}))
+	expectOK(t, ts.do(http.MethodPut, "/posts/"+strconv.Itoa(alicePost), alice, gin.H{"content": "alice 修改后的说说"}))
+	expectOK(t, ts.do(http.MethodDelete, "/posts/"+strconv.Itoa(ts.createPost(alice, "已删除的说说")), alice, nil))
+
+	// bob 的说说，alice 点赞、评论并点赞 bob 的评论，bob 点赞 alice 的评论
+	bobPost := ts.createPost(bob, "bob 的说说")
+	expectOK(t, ts.do(http.MethodPost, "/posts", bob, gin.H{"content": "只给 alice 看", "visibility": "custom", "audienceIds": []int{aliceID}}))
+	bobPostPath := "/posts/" + strconv.Itoa(bobPost)
+	expectOK(t, ts.do(http.MethodPost, bobPostPath+"/like", alice, nil))
+	var comment struct {
+		ID int `json:"id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, bobPostPath+"/comments", bob, gin.H{"content": "bob 的评论"}), &comment)
+	bobComment := comment.ID
+	expectOK(t, ts.do(http.MethodPost, "/comments/"+strconv.Itoa(bobComment)+"/like", alice, nil))
+	mustDecode(t, ts.do(http.MethodPost, bobPostPath+"/comments", alice, gin.H{"content": "alice 的评论"}), &comment)
+	expectOK(t, ts.do(http.MethodPost, "/comments/"+strconv.Itoa(comment.ID)+"/like", bob, nil))
+
+	blessing := gin.H{"content": "新春快乐", "font": "kaiti", "paper_style": "red"}
+	blessing["receiver_id"] = bobID
+	expectOK(t, ts.do(http.MethodPost, "/blessings", alice, blessing))
+	blessing["receiver_id"] = aliceID
+	expectOK(t, ts.do(http.MethodPost, "/blessings", bob, blessing))
+	ts.db.Create(&ChatMessage{SenderID: bobID, ReceiverID: aliceID, Content: "在吗", CreatedAt: time.Now()})
+	ts.redis.RPush(offlineKeyPrefix+strconv.Itoa(aliceID), `{"to":1}`)
+
+	ts.exportData(alice)
+
+	expectOK(t, ts.do(http.MethodDelete, "/account", alice, nil))
+	if n, err := ts.app.PurgeDueAccounts(context.Background()); err != nil || n != 0 {
+		t.Fatalf("等待期内不应清除: n=%d err=%v", n, err)
+	}
+	ts.passGracePeriod()
+	if n, err := ts.app.PurgeDueAccounts(context.Background()); err != nil || n != 1 {
+		t.Fatalf("应清除 1 个账户: n=%d err=%v", n, err)
+	}
+
+	for _, q := range []struct {
+		model interface{}
+		where string
+	}{
+		{&Post{}, "user_id = ?"},
+		{&Like{}, "user_id = ?"},
+		{&Comment{}, "user_id = ?"},
+		{&CommentLike{}, "user_id = ?"},
+		{&FriendRequest{}, "from_id = ? OR to_id = ?"},
+		{&FriendRelationship{}, "user_id = ? OR friend_id = ?"},
+		{&Blessing{}, "sender_id = ? OR receiver_id = ?"},
+		{&ChatMessage{}, "sender_id = ? OR receiver_id = ?"},
+		{&Avatar{}, "user_id = ?"},
+		{&DataExport{}, "user_id = ?"},
+		{&ProfileVisibility{}, "user_id = ?"},
+		{&PostAudience{}, "user_id = ?"},
+	} {
+		var n int64
+		args := []interface{}{aliceID}
+		if q.where != "user_id = ?" {
+			args = append(args, aliceID)
+		}
+		ts.db.Unscoped().Model(q.model).Where(q.where, args...).Count(&n)
+		if n != 0 {
+			t.Errorf("%T 中仍有 %d 条 alice 的数据", q.model, n)
+		}
+	}
+	var comments, edits int64
+	ts.db.Unscoped().Model(&Comment{}).Where("post_id = ?", alicePost).Count(&comments)
+	if comments != 0 {
+		t.Errorf("alice 说说下的评论应被删除，剩余 %d", comments)
+	}
+	ts.db.Model(&PostEdit{}).Count(&edits)
+	if edits != 0 {
+		t.Errorf("alice 说说的修改记录应被删除，剩余 %d", edits)
+	}
+
+	var post Post
+	ts.db.First(&post, bobPost)
+	var c Comment
+	ts.db.First(&c, bobComment)
+	if post.LikeCount != 0 || c.LikeCount != 0 {
+		t.Errorf("点赞数应扣减: post=%d comment=%d", post.LikeCount, c.LikeCount)
+	}
+	if _, err := os.Stat(filepath.Join(ts.cfg.Upload.Dir, strconv.Itoa(aliceID)+".png")); !os.IsNotExist(err) {
+		t.Errorf("头像文件应被删除: %v", err)
+	}
+	if ts.redis.Exists(offlineKeyPrefix + strconv.Itoa(aliceID)) {
+		t.Error("离线消息应被删除")
+	}
+
+	var user User
+	ts.db.Unscoped().First(&user, aliceID)
+	if user.UserName != "deleted#"+strconv.Itoa(aliceID) || user.Email != nil || user.NickName != "" || user.Password != "" || user.PurgedAt == nil {
+		t.Fatalf("用户记录未匿名化: %+v", user)
+	}
+	expectError(t, ts.do(http.MethodPost, "/account/restore", "", gin.H{"userName": "alice", "password": "password123"}), "INVALID_CREDENTIALS")
+
+	// 原用户名和邮箱可以重新注册
+	expectOK(t, ts.do(http.MethodPost, "/register", "", gin.H{"userName": "alice", "password": "password123", "email": "alice@example.com"}))
+	if n, err := ts.app.PurgeDueAccounts(context.Background()); err != nil || n != 0 {
+		t.Fatalf("已清除的账户不应重复处理: n=%d err=%v", n, err)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 用户角色，权限依次递增
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+// 上下文中保存当前用户角色的键
+const roleKey = "userRole"
+
+var (
+	ErrForbidden      = newAppError("FORBIDDEN", http.StatusForbidden, "没有权限执行该操作", "You do not have permission to perform this action")
+	ErrAccountBanned  = newAppError("ACCOUNT_BANNED", http.StatusForbidden, "账户已被封禁", "This account has been banned")
+	ErrInvalidUserID  = newAppError("INVALID_USER_ID", http.StatusBadRequest, "用户ID无效", "Invalid user id")
+	ErrCannotTargetMe = newAppError("CANNOT_TARGET_SELF", http.StatusBadRequest, "不能对自己执行该操作", "You cannot perform this action on yourself")
+)
+
+// 空角色视为普通用户，兼容迁移前创建的记录
+func normalizeRole(role string) string {
+	if _, ok := roleRank[role]; ok {
+		return role
+	}
+	return RoleUser
+}
+
+// 角色是否至少具有 min 的权限
+func hasRole(role, min string) bool {
+	return roleRank[normalizeRole(role)] >= roleRank[min]
+}
+
+// 要求当前用户至少具有指定角色，需在 authMiddleware 之后使用
+func requireRole(min string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !hasRole(c.GetString(roleKey), min) {
+			AbortWithError(c, ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// 管理后台中的用户信息
+type AdminUserView struct {
+	ID        int        `json:"id"`
+	UserName  string     `json:"userName"`
+	NickName  string     `json:"nickName"`
+	Email     *string    `json:"email"`
+	Role      string     `json:"role"`
+	BannedAt  *time.Time `json:"bannedAt"`
+	BanReason string     `json:"banReason"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// 用户列表查询条件
+type AdminUserQuery struct {
+	Q        string `form:"q"`
+	Role     string `form:"role" binding:"omitempty,oneof=user moderator admin"`
+	Banned   *bool  `form:"banned"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"pageSize" binding:"omitempty,min=1,max=100"`
+}
+
+// 封禁请求结构体
+type BanRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// 修改角色请求结构体
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// 转义 LIKE 通配符，配合 ESCAPE '!' 使用
+func likePattern(q string) string {
+	return "%" + likeEscaper.Replace(q) + "%"
+}
+
+// 用户管理服务
+type ModerationService struct {
+	db *gorm.DB
+}
+
+func NewModerationService(db *gorm.DB) *ModerationService {
+	return &ModerationService{db: db}
+}
+
+// 按条件分页查询用户，返回当前页和总数
+func (s *ModerationService) ListUsers(q AdminUserQuery) ([]AdminUserView, int64, error) {
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.PageSize == 0 {
+		q.PageSize = 20
+	}
+
+	query := s.db.Model(&User{})
+	if q.Q != "" {
+		pattern := likePattern(q.Q)
+		query = query.Where("user_name LIKE ? ESCAPE '!' OR nick_name LIKE ? ESCAPE '!'", pattern, pattern)
+	}
+	if q.Role != "" {
+		query = query.Where("role = ?", q.Role)
+	}
+	if q.Banned != nil {
+		if *q.Banned {
+			query = query.Where("banned_at IS NOT NULL")
+		} else {
+			query = query.Where("banned_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	users := []AdminUserView{}
+	err := query.Select("id, user_name, nick_name, email, role, banned_at, ban_reason, created_at").
+		Order("id").Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Scan(&users).Error
+	return users, total, err
+}
+
+// 管理员只能处理角色低于自己的用户，且不能处理自己
+func (s *ModerationService) target(actor *User, targetID int) (*User, error) {
+	if actor.ID == targetID {
+		return nil, ErrCannotTargetMe
+	}
+	var target User
+	if err := s.db.First(&target, targetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if roleRank[normalizeRole(target.Role)] >= roleRank[normalizeRole(actor.Role)] {
+		return nil, ErrForbidden
+	}
+	return &target, nil
+}
+
+// 封禁用户
+func (s *ModerationService) Ban(actor *User, targetID int, reason string) error {
+	if _, err := s.target(actor, targetID); err != nil {
+		return err
+	}
+	return s.db.Model(&User{}).Where("id = ?", targetID).
+		Updates(map[string]interface{}{"banned_at": time.Now(), "ban_reason": reason}).Error
+}
+
+// 解除封禁
+func (s *ModerationService) Unban(actor *User, targetID int) error {
+	if _, err := s.target(actor, targetID); err != nil {
+		return err
+	}
+	return s.db.Model(&User{}).Where("id = ?", targetID).
+		Updates(map[string]interface{}{"banned_at": nil, "ban_reason": ""}).Error
+}
+
+// 修改用户角色，只有管理员可以调用，不能修改其他管理员的角色
+func (s *ModerationService) SetRole(actor *User, targetID int, role string) error {
+	if _, err := s.target(actor, targetID); err != nil {
+		return err
+	}
+	return s.db.Model(&User{}).Where("id = ?", targetID).Update("role", role).Error
+}
+
+// 执行 set-role 子命令：set-role <用户名> <角色>，用于设置第一个管理员
+func runSetRole(db *gorm.DB, args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("用法: set-role <用户名> user|moderator|admin")
+	}
+	userName, role := args[0], args[1]
+	if _, ok := roleRank[role]; !ok {
+		return fmt.Errorf("未知的角色 %q，可用: user|moderator|admin", role)
+	}
+	res := db.Model(&User{}).Where("user_name = ?", userName).Update("role", role)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("用户 %q 不存在", userName)
+	}
+	fmt.Fprintf(out, "已将 %s 的角色设置为 %s\n", userName, role)
+	return nil
+}
+
+// 当前登录用户，需在 authMiddleware 之后使用
+func (a *App) currentUser(c *gin.Context) (*User, error) {
+	return a.users.Get(c.MustGet("userID").(int))
+}
+
+// 解析路径中的用户ID
+func userIDParam(c *gin.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, ErrInvalidUserID
+	}
+	return id, nil
+}
+
+// 查询用户列表
+func (a *App) adminListUsersHandler(c *gin.Context) {
+	var q AdminUserQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	users, total, err := a.moderation.ListUsers(q)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"users": users, "total": total}, "查询成功")
+}
+
+// 封禁用户并吊销其所有会话
+func (a *App) adminBanUserHandler(c *gin.Context) {
+	targetID, err := userIDParam(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	var req BanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+	actor, err := a.currentUser(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	if err := a.moderation.Ban(actor, targetID, req.Reason); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	if err := a.auth.LogoutAll(c.Request.Context(), targetID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "已封禁")
+}
+
+// 解除封禁
+func (a *App) adminUnbanUserHandler(c *gin.Context) {
+	targetID, err := userIDParam(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	actor, err := a.currentUser(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	if err := a.moderation.Unban(actor, targetID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "已解除封禁")
+}
+
+// 修改用户角色
+func (a *App) adminSetRoleHandler(c *gin.Context) {
+	targetID, err := userIDParam(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+	actor, err := a.currentUser(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	if err := a.moderation.SetRole(actor, targetID, req.Role); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "角色已更新")
+}
+
+// 删除说说
+func (a *App) adminDeletePostHandler(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ResponseError(c, ErrInvalidPostID)
+		return
+	}
+	if err := a.posts.Delete(postID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "说说已删除")
+}
+
+// 删除评论
+func (a *App) adminDeleteCommentHandler(c *gin.Context) {
+	commentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ResponseError(c, ErrInvalidCommentID)
+		return
+	}
+	if err := a.comments.Delete(commentID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "评论已删除")
+}
+
+// 删除祝福
+func (a *App) adminDeleteBlessingHandler(c *gin.Context) {
+	blessingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ResponseError(c, ErrBlessingNotFound)
+		return
+	}
+	if err := a.blessings.Delete(blessingID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "祝福已删除")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 直接修改用户角色，模拟通过 set-role 命令设置管理员
+func (ts *testServer) setRole(userName, role string) {
+	ts.t.Helper()
+	var out bytes.Buffer
+	if err := runSetRole(ts.db, []string{userName, role}, &out); err != nil {
+		ts.t.Fatal(err)
+	}
+}
+
+func TestAdminRequiresRole(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	bobID, _ := ts.signup("bob")
+
+	expectError(t, ts.do(http.MethodGet, "/admin/users", alice, nil), "FORBIDDEN")
+
+	// 版主可以访问后台，但不能修改角色
+	ts.setRole("alice", RoleModerator)
+	expectOK(t, ts.do(http.MethodGet, "/admin/users", alice, nil))
+	expectError(t, ts.do(http.MethodPut, "/admin/users/"+strconv.Itoa(bobID)+"/role", alice, gin.H{"role": RoleModerator}), "FORBIDDEN")
+
+	ts.setRole("alice", RoleAdmin)
+	expectOK(t, ts.do(http.MethodPut, "/admin/users/"+strconv.Itoa(bobID)+"/role", alice, gin.H{"role": RoleModerator}))
+	expectError(t, ts.do(http.MethodPut, "/admin/users/"+strconv.Itoa(bobID)+"/role", alice, gin.H{"role": "superuser"}), "INVALID_REQUEST")
+	expectError(t, ts.do(http.MethodPut, "/admin/users/9999/role", alice, gin.H{"role": RoleModerator}), "USER_NOT_FOUND")
+
+	// 管理员不能修改其他管理员的角色
+	carolID, carol := ts.signup("carol")
+	ts.setRole("carol", RoleAdmin)
+	expectError(t, ts.do(http.MethodPut, "/admin/users/"+strconv.Itoa(carolID)+"/role", alice, gin.H{"role": RoleUser}), "FORBIDDEN")
+	expectError(t, ts.do(http.MethodPut, "/admin/users/"+strconv.Itoa(carolID)+"/role", alice, gin.H{"role": RoleModerator}), "FORBIDDEN")
+	var role string
+	ts.db.Model(&User{}).Where("id = ?", carolID).Pluck("role", &role)
+	if role != RoleAdmin {
+		t.Fatalf("其他管理员的角色不应被修改: %s", role)
+	}
+	expectOK(t, ts.do(http.MethodGet, "/admin/users", carol, nil))
+
+	var out bytes.Buffer
+	if err := runSetRole(ts.db, []string{"nobody", RoleAdmin}, &out); err == nil {
+		t.Fatal("用户不存在时应返回错误")
+	}
+	if err := runSetRole(ts.db, []string{"alice", "root"}, &out); err == nil {
+		t.Fatal("未知角色应返回错误")
+	}
+}
+
+func TestAdminBanUser(t *testing.T) {
+	ts := newTestServer(t)
+	_, mod := ts.signup("moderator1")
+	bobID, bob := ts.signup("bob")
+	adminID, admin := ts.signup("admin1")
+	ts.setRole("moderator1", RoleModerator)
+	ts.setRole("admin1", RoleAdmin)
+	banPath := "/admin/users/" + strconv.Itoa(bobID) + "/ban"
+
+	expectError(t, ts.do(http.MethodPost, banPath, mod, gin.H{}), "INVALID_REQUEST")
+	expectOK(t, ts.do(http.MethodPost, banPath, mod, gin.H{"reason": "spam"}))
+	expectError(t, ts.do(http.MethodGet, "/posts", bob, nil), "TOKEN_REVOKED")
+	expectError(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "bob", "password": "password123"}), "ACCOUNT_BANNED")
+	// 密码错误时不提示封禁
+	expectError(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "bob", "password": "wrong"}), "INVALID_CREDENTIALS")
+
+	var list struct {
+		Users []AdminUserView `json:"users"`
+		Total int64           `json:"total"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/admin/users?banned=true", mod, nil), &list)
+	if list.Total != 1 || list.Users[0].ID != bobID || list.Users[0].BanReason != "spam" {
+		t.Fatalf("封禁用户列表不符: %+v", list)
+	}
+
+	expectOK(t, ts.do(http.MethodDelete, banPath, mod, nil))
+	ts.login("bob")
+
+	// 版主不能处理管理员或自己
+	expectError(t, ts.do(http.MethodPost, "/admin/users/"+strconv.Itoa(adminID)+"/ban", mod, gin.H{"reason": "x"}), "FORBIDDEN")
+	expectError(t, ts.do(http.MethodPost, "/admin/users/"+strconv.Itoa(adminID)+"/ban", admin, gin.H{"reason": "x"}), "CANNOT_TARGET_SELF")
+	expectError(t, ts.do(http.MethodPost, "/admin/users/999/ban", admin, gin.H{"reason": "x"}), "USER_NOT_FOUND")
+}
+
+func TestAdminListUsersSearch(t *testing.T) {
+	ts := newTestServer(t)
+	_, admin := ts.signup("admin1")
+	ts.setRole("admin1", RoleAdmin)
+	ts.signup("alice")
+	ts.signup("ali_ce")
+	ts.signup("bob")
+
+	var list struct {
+		Users []AdminUserView `json:"users"`
+		Total int64           `json:"total"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/admin/users?q=ali&pageSize=1&page=2", admin, nil), &list)
+	if list.Total != 2 || len(list.Users) != 1 || list.Users[0].UserName != "ali_ce" {
+		t.Fatalf("搜索结果不符: %+v", list)
+	}
+	// 下划线按字面匹配，而不是通配符
+	mustDecode(t, ts.do(http.MethodGet, "/admin/users?q=i_c", admin, nil), &list)
+	if list.Total != 1 || list.Users[0].UserName != "ali_ce" {
+		t.Fatalf("通配符未转义: %+v", list)
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/admin/users?role=admin", admin, nil), &list)
+	if list.Total != 1 || list.Users[0].Role != RoleAdmin {
+		t.Fatalf("按角色筛选不符: %+v", list)
+	}
+}
+
+func TestAdminDeleteContent(t *testing.T) {
+	ts := newTestServer(t)
+	_, mod := ts.signup("moderator1")
+	ts.setRole("moderator1", RoleModerator)
+	_, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	ts.befriend(alice, bob, bobID)
+
+	postID := ts.createPost(alice, "广告")
+	postPath := "/posts/" + strconv.Itoa(postID)
+	var comment struct {
+		ID int `json:"id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, postPath+"/comments", bob, gin.H{"content": "同乐"}), &comment)
+	expectOK(t, ts.do(http.MethodPost, "/comments/"+strconv.Itoa(comment.ID)+"/like", alice, nil))
+	expectOK(t, ts.do(http.MethodPost, postPath+"/like", bob, nil))
+
+	expectOK(t, ts.do(http.MethodDelete, "/admin/posts/"+strconv.Itoa(postID), mod, nil))
+	expectError(t, ts.do(http.MethodDelete, "/admin/posts/"+strconv.Itoa(postID), mod, nil), "POST_NOT_FOUND")
+	// 说说被软删除，其下的评论和点赞随之隐藏
+	var posts int64
+	ts.db.Model(&Post{}).Count(&posts)
+	if posts != 0 {
+		t.Fatalf("说说应被删除，剩余 %d", posts)
+	}
+	expectError(t, ts.do(http.MethodGet, postPath+"/comments", bob, nil), "POST_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPost, "/comments/"+strconv.Itoa(comment.ID)+"/like", bob, nil), "COMMENT_NOT_FOUND")
+	if liked := ts.listPosts(bob, "/posts/liked").Posts; len(liked) != 0 {
+		t.Fatalf("点赞列表不应显示已删除的说说: %+v", liked)
+	}
+
+	other := ts.createPost(alice, "新年快乐")
+	mustDecode(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(other)+"/comments", bob, gin.H{"content": "同乐"}), &comment)
+	expectOK(t, ts.do(http.MethodDelete, "/admin/comments/"+strconv.Itoa(comment.ID), mod, nil))
+	expectError(t, ts.do(http.MethodDelete, "/admin/comments/"+strconv.Itoa(comment.ID), mod, nil), "COMMENT_NOT_FOUND")
+
+	var blessing struct {
+		ID int `json:"blessing_id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, "/blessings/share", alice, gin.H{"content": "福", "font": "kai", "paper_style": "red"}), &blessing)
+	expectOK(t, ts.do(http.MethodDelete, "/admin/blessings/"+strconv.Itoa(blessing.ID), mod, nil))
+	expectError(t, ts.do(http.MethodDelete, "/admin/blessings/"+strconv.Itoa(blessing.ID), mod, nil), "BLESSING_NOT_FOUND")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// 应用容器，持有数据库、Redis、聊天中心和配置，所有 HTTP 处理函数都是它的方法
+type App struct {
+	cfg  Config
+	db   *gorm.DB
+	rdb  *redis.Client
+	auth *Auth
+	hub  *Hub
+
+	limiter *RateLimiter
+	lockout *Lockout
+	resets  *PasswordResets
+	mailer  Mailer
+
+	twoFactor *TwoFactorService
+
+	users     *UserService
+	privacy   *PrivacyService
+	posts     *PostService
+	comments  *CommentService
+	friends   *FriendService
+	blessings *BlessingService
+	avatars   *AvatarService
+
+	moderation *ModerationService
+	reports    *ReportService
+	accounts   *AccountService
+	exports    *ExportService
+}
+
+func NewApp(cfg Config, db *gorm.DB, rdb *redis.Client) (*App, error) {
+	policy, err := NewPolicy(cfg.Policy)
+	if err != nil {
+		return nil, err
+	}
+	friends := NewFriendService(db)
+	privacy := NewPrivacyService(db, friends)
+	comments := NewCommentService(db)
+	return &App{
+		cfg:  cfg,
+		db:   db,
+		rdb:  rdb,
+		auth: NewAuth(cfg.JWT, rdb),
+		hub:  NewHub(db, rdb),
+
+		limiter: NewRateLimiter(rdb),
+		lockout: NewLockout(rdb, cfg.Lockout),
+		resets:  NewPasswordResets(rdb, cfg.Reset.TTL),
+		mailer:  NewMailer(cfg.Mail),
+
+		twoFactor: NewTwoFactorService(db, rdb, cfg.TwoFactor),
+
+		users:     NewUserService(db, policy),
+		privacy:   privacy,
+		posts:     NewPostService(db),
+		comments:  comments,
+		friends:   friends,
+		blessings: NewBlessingService(db, friends),
+		avatars:   NewAvatarService(db, cfg.Upload.Dir),
+
+		moderation: NewModerationService(db),
+		reports:    NewReportService(db, comments),
+		accounts:   NewAccountService(db, cfg.Account),
+		exports:    NewExportService(db, friends, privacy, cfg.Export, cfg.Upload.Dir),
+	}, nil
+}
+
+// 释放应用持有的资源：先关闭聊天连接并刷新离线消息，再关闭 Redis 和数据库连接池
+func (a *App) Close(ctx context.Context) error {
+	var errs []error
+	if err := a.hub.Close(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("关闭聊天服务: %w", err))
+	}
+	if err := a.rdb.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("关闭 Redis: %w", err))
+	}
+	if sqlDB, err := a.db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("关闭数据库: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
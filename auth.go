@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+// 上下文中保存当前访问令牌声明的键
+const claimsKey = "tokenClaims"
+
+// Redis 键前缀
+const (
+	sessionKeyPrefix      = "session:"       // 登录会话（刷新令牌族），hash: user_id、refresh、user_agent、ip、created_at、last_seen
+	userSessionsKeyPrefix = "user_sessions:" // 用户的全部会话ID
+	revokedJTIKeyPrefix   = "revoked_jti:"   // 已吊销的访问令牌
+)
+
+var (
+	ErrRefreshTokenInvalid = newAppError("REFRESH_TOKEN_INVALID", http.StatusUnauthorized, "刷新令牌无效或已过期", "Invalid or expired refresh token")
+	ErrTokenRevoked        = newAppError("TOKEN_REVOKED", http.StatusUnauthorized, "令牌已失效，请重新登录", "Token has been revoked, please log in again")
+)
+
+// 访问令牌声明，sid 为所属会话，jti 用于单独吊销
+type AccessClaims struct {
+	UserID    int    `json:"user_id"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// 登录或刷新后返回给客户端的令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // 访问令牌有效期（秒）
+}
+
+// 刷新令牌请求结构体
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// 令牌签发与校验。访问令牌是短期 JWT；刷新令牌是不透明的随机串，
+// 以会话为单位保存在 Redis 中，每次刷新都会轮换，旧令牌被重复使用时吊销整个会话
+type Auth struct {
+	secret     []byte
+	ttl        time.Duration
+	refreshTTL time.Duration
+	rdb        *redis.Client
+}
+
+func NewAuth(cfg JWTConfig, rdb *redis.Client) *Auth {
+	return &Auth{secret: []byte(cfg.Secret), ttl: cfg.TTL, refreshTTL: cfg.RefreshTTL, rdb: rdb}
+}
+
+// 生成 n 字节的随机串
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 签发访问令牌
+func (a *Auth) generateToken(userID int, sessionID string) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := AccessClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(a.ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.secret)
+}
+
+// 解析访问令牌
+func (a *Auth) parseToken(tokenString string) (*AccessClaims, error) {
+	var claims AccessClaims
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return a.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("无效的令牌: %w", err)
+	}
+	if claims.UserID == 0 || claims.SessionID == "" || claims.ID == "" {
+		return nil, errors.New("无效的令牌声明")
+	}
+	return &claims, nil
+}
+
+// 刷新令牌格式为 <会话ID>.<随机串>
+func splitRefreshToken(token string) (sessionID, secret string, ok bool) {
+	sessionID, secret, ok = strings.Cut(token, ".")
+	return sessionID, secret, ok && sessionID != "" && secret != ""
+}
+
+// 为用户创建新的登录会话并签发令牌
+func (a *Auth) Login(ctx context.Context, userID int, meta SessionMeta) (*TokenPair, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	key := sessionKeyPrefix + sessionID
+	userKey := userSessionsKeyPrefix + strconv.Itoa(userID)
+	_, err = a.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		now := time.Now().Unix()
+		p.HSet(ctx, key, "user_id", userID, "refresh", hashToken(secret),
+			"user_agent", meta.UserAgent, "ip", meta.IP, "created_at", now, "last_seen", now)
+		p.Expire(ctx, key, a.refreshTTL)
+		p.SAdd(ctx, userKey, sessionID)
+		p.Expire(ctx, userKey, a.refreshTTL)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a.tokenPair(userID, sessionID, secret)
+}
+
+func (a *Auth) tokenPair(userID int, sessionID, secret string) (*TokenPair, error) {
+	access, err := a.generateToken(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: sessionID + "." + secret,
+		ExpiresIn:    int64(a.ttl / time.Second),
+	}, nil
+}
+
+// 使用刷新令牌换取新的令牌对，旧的刷新令牌随即失效
+func (a *Auth) Refresh(ctx context.Context, refreshToken string, meta SessionMeta) (*TokenPair, error) {
+	sessionID, secret, ok := splitRefreshToken(refreshToken)
+	if !ok {
+		return nil, ErrRefreshTokenInvalid
+	}
+	newSecret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	key := sessionKeyPrefix + sessionID
+	var userID int
+	reused := false
+	err = a.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		session, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(session) == 0 {
+			return ErrRefreshTokenInvalid
+		}
+		userID, _ = strconv.Atoi(session["user_id"])
+		if session["refresh"] != hashToken(secret) {
+			// 已轮换的旧令牌被再次使用，说明令牌可能泄露，吊销整个会话
+			reused = true
+			_, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+				p.Del(ctx, key)
+				p.SRem(ctx, userSessionsKeyPrefix+strconv.Itoa(userID), sessionID)
+				return nil
+			})
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			userKey := userSessionsKeyPrefix + strconv.Itoa(userID)
+			p.HSet(ctx, key, "refresh", hashToken(newSecret), "ip", meta.IP, "last_seen", time.Now().Unix())
+			p.Expire(ctx, key, a.refreshTTL)
+			p.Expire(ctx, userKey, a.refreshTTL)
+			return nil
+		})
+		return err
+	}, key)
+	switch {
+	case errors.Is(err, redis.TxFailedErr):
+		// 并发刷新，只有一个请求能成功
+		return nil, ErrRefreshTokenInvalid
+	case err != nil:
+		return nil, err
+	case reused:
+		log.Printf("检测到刷新令牌重复使用，已吊销用户 %d 的会话 %s", userID, sessionID)
+		return nil, ErrRefreshTokenInvalid
+	}
+	return a.tokenPair(userID, sessionID, newSecret)
+}
+
+// 校验访问令牌：签名和有效期、jti 未被吊销、所属会话仍然存在，并更新会话的最近活动
+func (a *Auth) Verify(ctx context.Context, tokenString string, meta SessionMeta) (*AccessClaims, error) {
+	claims, err := a.parseToken(tokenString)
+	if err != nil {
+		return nil, ErrTokenInvalid.Wrap(err)
+	}
+
+	var revoked *redis.IntCmd
+	var session *redis.SliceCmd
+	key := sessionKeyPrefix + claims.SessionID
+	_, err = a.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		revoked = p.Exists(ctx, revokedJTIKeyPrefix+claims.ID)
+		session = p.HMGet(ctx, key, "user_id", "last_seen")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if revoked.Val() > 0 || session.Val()[0] == nil {
+		return nil, ErrTokenRevoked
+	}
+	a.touch(ctx, key, session.Val()[1], meta)
+	return claims, nil
+}
+
+// 吊销访问令牌，记录保留到令牌自然过期
+func (a *Auth) revoke(p redis.Pipeliner, ctx context.Context, claims *AccessClaims) {
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl > 0 {
+		p.Set(ctx, revokedJTIKeyPrefix+claims.ID, 1, ttl)
+	}
+}
+
+// 退出当前会话：吊销当前访问令牌并删除会话，该会话的刷新令牌随之失效
+func (a *Auth) Logout(ctx context.Context, claims *AccessClaims) error {
+	_, err := a.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		a.revoke(p, ctx, claims)
+		p.Del(ctx, sessionKeyPrefix+claims.SessionID)
+		p.SRem(ctx, userSessionsKeyPrefix+strconv.Itoa(claims.UserID), claims.SessionID)
+		return nil
+	})
+	return err
+}
+
+// 退出用户的全部会话，所有设备上的访问令牌和刷新令牌都会失效
+func (a *Auth) LogoutAll(ctx context.Context, userID int) error {
+	userKey := userSessionsKeyPrefix + strconv.Itoa(userID)
+	sessionIDs, err := a.rdb.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(sessionIDs)+1)
+	for _, id := range sessionIDs {
+		keys = append(keys, sessionKeyPrefix+id)
+	}
+	keys = append(keys, userKey)
+	return a.rdb.Del(ctx, keys...).Err()
+}
+
+// 当前请求的访问令牌声明，仅在认证路由中可用
+func currentClaims(c *gin.Context) *AccessClaims {
+	return c.MustGet(claimsKey).(*AccessClaims)
+}
+
+// 刷新令牌
+func (a *App) refreshHandler(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	tokens, err := a.auth.Refresh(c.Request.Context(), req.RefreshToken, requestMeta(c))
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, tokens, "刷新成功")
+}
+
+// 退出登录
+func (a *App) logoutHandler(c *gin.Context) {
+	if err := a.auth.Logout(c.Request.Context(), currentClaims(c)); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "已退出登录")
+}
+
+// 退出所有设备
+func (a *App) logoutAllHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	if err := a.auth.LogoutAll(c.Request.Context(), userID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "已退出所有设备")
+}
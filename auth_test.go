@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 登录并返回令牌对
+func (ts *testServer) login(userName string) TokenPair {
+	ts.t.Helper()
+	var tokens TokenPair
+	mustDecode(ts.t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": userName, "password": "password123"}), &tokens)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.ExpiresIn <= 0 {
+		ts.t.Fatalf("登录结果不完整: %+v", tokens)
+	}
+	return tokens
+}
+
+func (ts *testServer) refresh(refreshToken string) apiResponse {
+	ts.t.Helper()
+	return ts.do(http.MethodPost, "/auth/refresh", "", gin.H{"refreshToken": refreshToken})
+}
+
+func TestRefreshRotation(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup("alice")
+	first := ts.login("alice")
+
+	var second TokenPair
+	mustDecode(t, ts.refresh(first.RefreshToken), &second)
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("刷新后应轮换刷新令牌")
+	}
+	expectOK(t, ts.do(http.MethodGet, "/posts", second.AccessToken, nil))
+
+	// 重复使用已轮换的刷新令牌会吊销整个会话
+	expectError(t, ts.refresh(first.RefreshToken), "REFRESH_TOKEN_INVALID")
+	expectError(t, ts.refresh(second.RefreshToken), "REFRESH_TOKEN_INVALID")
+	expectError(t, ts.do(http.MethodGet, "/posts", second.AccessToken, nil), "TOKEN_REVOKED")
+
+	expectError(t, ts.refresh("garbage"), "REFRESH_TOKEN_INVALID")
+	expectError(t, ts.do(http.MethodPost, "/auth/refresh", "", gin.H{}), "INVALID_REQUEST")
+}
+
+func TestLogout(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup("alice")
+	phone := ts.login("alice")
+	laptop := ts.login("alice")
+
+	expectOK(t, ts.do(http.MethodPost, "/auth/logout", phone.AccessToken, nil))
+	expectError(t, ts.do(http.MethodGet, "/posts", phone.AccessToken, nil), "TOKEN_REVOKED")
+	expectError(t, ts.refresh(phone.RefreshToken), "REFRESH_TOKEN_INVALID")
+	if ttl := ts.redis.TTL(revokedJTIKeyPrefix + mustParse(t, ts, phone.AccessToken).ID); ttl <= 0 {
+		t.Fatalf("吊销记录应随令牌过期，实际 TTL=%v", ttl)
+	}
+
+	// 其他设备不受影响
+	expectOK(t, ts.do(http.MethodGet, "/posts", laptop.AccessToken, nil))
+}
+
+func TestLogoutAll(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup("alice")
+	phone := ts.login("alice")
+	laptop := ts.login("alice")
+
+	expectOK(t, ts.do(http.MethodPost, "/auth/logout-all", laptop.AccessToken, nil))
+	for _, tokens := range []TokenPair{phone, laptop} {
+		expectError(t, ts.do(http.MethodGet, "/posts", tokens.AccessToken, nil), "TOKEN_REVOKED")
+		expectError(t, ts.refresh(tokens.RefreshToken), "REFRESH_TOKEN_INVALID")
+	}
+
+	// 重新登录后恢复正常
+	expectOK(t, ts.do(http.MethodGet, "/posts", ts.login("alice").AccessToken, nil))
+}
+
+func mustParse(t *testing.T, ts *testServer, token string) *AccessClaims {
+	t.Helper()
+	claims, err := ts.app.auth.parseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -16,27 +18,76 @@ type Blessing struct {
 	CreatedAt  time.Time `gorm:"autoCreateTime"`
 }
 
-func SendBlessings(c *gin.Context) {
-	var req struct {
-		ReceiverID *int   `json:"receiver_id"`
-		Content    string `json:"content"`
-		Font       string `json:"font"`
-		PaperStyle string `json:"paper_style"`
-	}
+// 发送或分享祝福的请求体
+type BlessingRequest struct {
+	ReceiverID *int   `json:"receiver_id"`
+	Content    string `json:"content"`
+	Font       string `json:"font"`
+	PaperStyle string `json:"paper_style"`
+}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "Invalid request format")
-		return
-	}
+// 已发出的祝福
+type SentBlessing struct {
+	ID           int       `json:"id"`
+	ReceiverID   int       `json:"receiver_id"`
+	ReceiverName string    `json:"receiver_name"`
+	Content      string    `json:"content"`
+	Font         string    `json:"font"`
+	PaperStyle   string    `json:"paper_style"`
+	Timestamp    string    `json:"timestamp"`
+	CreatedAt    time.Time `json:"-"` // 用于生成分页游标
+}
 
-	senderID := c.GetInt("userID")
+// 草稿箱中的祝福
+type DraftBlessing struct {
+	ID         int       `json:"id"`
+	ReceiverID *int      `json:"receiver_id"`
+	Content    string    `json:"content"`
+	Font       string    `json:"font"`
+	PaperStyle string    `json:"paper_style"`
+	Timestamp  string    `json:"timestamp"`
+	CreatedAt  time.Time `json:"-"`
+}
+
+// 收到的祝福
+type ReceivedBlessing struct {
+	ID         int       `json:"id"`
+	SenderID   uint      `json:"sender_id"`
+	SenderName string    `json:"sender_name"`
+	Content    string    `json:"content"`
+	Font       string    `json:"font"`
+	PaperStyle string    `json:"paper_style"`
+	Timestamp  string    `json:"timestamp"`
+	CreatedAt  time.Time `json:"-"`
+}
+
+var (
+	ErrNotFriend         = newAppError("NOT_FRIEND", http.StatusForbidden, "对方不是你的好友", "The recipient is not your friend")
+	ErrBlessingNotFound  = newAppError("BLESSING_NOT_FOUND", http.StatusNotFound, "没有查询到这条祝福", "Blessing not found")
+	ErrBlessingForbidden = newAppError("BLESSING_FORBIDDEN", http.StatusForbidden, "您无权查看已发送给别人的祝福", "This blessing was sent to someone else")
+)
+
+// 祝福领域服务
+type BlessingService struct {
+	db      *gorm.DB
+	friends *FriendService
+}
+
+func NewBlessingService(db *gorm.DB, friends *FriendService) *BlessingService {
+	return &BlessingService{db: db, friends: friends}
+}
 
+// 发送祝福，未指定接收人时存入草稿箱
+func (s *BlessingService) Send(senderID int, req BlessingRequest) (*Blessing, error) {
 	//确保只能发给好友
-	var count int64
-	db.Table("friend_relationships").Where("(user_id = ? and friend_id = ?) or (user_id = ? and friend_id = ?)", senderID, req.ReceiverID, req.ReceiverID, senderID).Count(&count)
-	if count == 0 && req.ReceiverID != nil {
-		ResponseFAIL(c, http.StatusForbidden, "对方不是你的好友")
-		return
+	if req.ReceiverID != nil {
+		ok, err := s.friends.AreFriends(senderID, *req.ReceiverID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNotFriend
+		}
 	}
 
 	blessing := Blessing{
@@ -46,9 +97,112 @@ func SendBlessings(c *gin.Context) {
 		Font:       req.Font,
 		PaperStyle: req.PaperStyle,
 	}
+	if err := s.db.Create(&blessing).Error; err != nil {
+		return nil, err
+	}
+	return &blessing, nil
+}
+
+// 分页查询自己发送的祝福
+func (s *BlessingService) Sent(userID int, p page) ([]SentBlessing, string, error) {
+	blessings := []SentBlessing{}
+	query := s.db.Table("blessings").
+		Select("blessings.id, blessings.receiver_id, users.user_name as receiver_name, blessings.content, blessings.font, blessings.paper_style, blessings.created_at as timestamp, blessings.created_at").
+		Joins("JOIN users ON blessings.receiver_id = users.id").
+		Where("users.deleted_at IS NULL").
+		Where("blessings.sender_id = ?", userID)
+	if err := p.apply(query, "blessings.created_at", "blessings.id").Scan(&blessings).Error; err != nil {
+		return nil, "", err
+	}
+	blessings, next := pageResult(blessings, p, func(b SentBlessing) (time.Time, int) { return b.CreatedAt, b.ID })
+	return blessings, next, nil
+}
 
-	if err := db.Create(&blessing).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "发送祝福失败")
+// 分页查询草稿箱
+func (s *BlessingService) Drafts(userID int, p page) ([]DraftBlessing, string, error) {
+	drafts := []DraftBlessing{}
+	query := s.db.Table("blessings").
+		Select("blessings.id, blessings.receiver_id, blessings.content, blessings.font, blessings.paper_style, blessings.created_at as timestamp, blessings.created_at").
+		Where("(blessings.sender_id = ?) and (blessings.receiver_id is null)", userID)
+	if err := p.apply(query, "blessings.created_at", "blessings.id").Scan(&drafts).Error; err != nil {
+		return nil, "", err
+	}
+	drafts, next := pageResult(drafts, p, func(b DraftBlessing) (time.Time, int) { return b.CreatedAt, b.ID })
+	return drafts, next, nil
+}
+
+// 删除祝福
+func (s *BlessingService) Delete(blessingID int) error {
+	res := s.db.Delete(&Blessing{}, blessingID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrBlessingNotFound
+	}
+	return nil
+}
+
+// 分页查询自己收到的祝福
+func (s *BlessingService) Received(userID int, p page) ([]ReceivedBlessing, string, error) {
+	blessings := []ReceivedBlessing{}
+	query := s.db.Table("blessings").
+		Select("blessings.id, blessings.sender_id, users.user_name as sender_name, blessings.content, blessings.font, blessings.paper_style, blessings.created_at as timestamp, blessings.created_at").
+		Joins("JOIN users ON blessings.sender_id = users.id").
+		Where("users.deleted_at IS NULL").
+		Where("blessings.receiver_id = ?", userID)
+	if err := p.apply(query, "blessings.created_at", "blessings.id").Scan(&blessings).Error; err != nil {
+		return nil, "", err
+	}
+	blessings, next := pageResult(blessings, p, func(b ReceivedBlessing) (time.Time, int) { return b.CreatedAt, b.ID })
+	return blessings, next, nil
+}
+
+// 创建一条可通过链接领取的祝福
+func (s *BlessingService) Share(senderID int, req BlessingRequest) (*Blessing, error) {
+	blessing := Blessing{
+		SenderID:   senderID,
+		ReceiverID: nil,
+		Content:    req.Content,
+		Font:       req.Font,
+		PaperStyle: req.PaperStyle,
+	}
+	if err := s.db.Create(&blessing).Error; err != nil {
+		return nil, err
+	}
+	return &blessing, nil
+}
+
+// 通过链接领取祝福，首次领取的人成为接收人
+func (s *BlessingService) Receive(userID, blessingID int) (*Blessing, error) {
+	var blessing Blessing
+	if err := s.db.First(&blessing, blessingID).Error; err != nil {
+		return nil, ErrBlessingNotFound
+	}
+
+	if blessing.ReceiverID == nil {
+		blessing.ReceiverID = &userID
+		if err := s.db.Save(&blessing).Error; err != nil {
+			return nil, err
+		}
+	} else if *blessing.ReceiverID != userID {
+		return nil, ErrBlessingForbidden
+	}
+	return &blessing, nil
+}
+
+func (a *App) SendBlessings(c *gin.Context) {
+	var req BlessingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	senderID := c.GetInt("userID")
+
+	blessing, err := a.blessings.Send(senderID, req)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 
@@ -59,113 +213,74 @@ func SendBlessings(c *gin.Context) {
 	}
 }
 
-// 查询自己发送的祝福
-func GetSentBlessings(c *gin.Context) {
+// 查询自己发送的祝福和草稿箱，两者分别分页：cursor 用于已发送的祝福，draftsCursor 用于草稿箱
+func (a *App) GetSentBlessings(c *gin.Context) {
 	userID := c.GetInt("userID")
-
-	var blessings []struct {
-		ID           int    `json:"id"`
-		ReceiverID   int    `json:"receiver_id"`
-		ReceiverName string `json:"receiver_name"`
-		Content      string `json:"content"`
-		Font         string `json:"font"`
-		PaperStyle   string `json:"paper_style"`
-		Timestamp    string `json:"timestamp"`
-	}
-	err := db.Table("blessings").
-		Select("blessings.id, blessings.receiver_id, users.user_name as receiver_name, blessings.content, blessings.font, blessings.paper_style, blessings.created_at as timestamp").
-		Joins("JOIN users ON blessings.receiver_id = users.id").
-		Where("blessings.sender_id = ?", userID).
-		Order("blessings.created_at DESC").
-		Scan(&blessings).Error
-
+	p, err := bindPage(c)
 	if err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "无法获取发出的祝福")
+		ResponseError(c, err)
 		return
 	}
-
-	var drafts []struct {
-		ID         int    `json:"id"`
-		ReceiverID *int   `json:"receiver_id"`
-		Content    string `json:"content"`
-		Font       string `json:"font"`
-		PaperStyle string `json:"paper_style"`
-		Timestamp  string `json:"timestamp"`
+	draftsPage := page{limit: p.limit}
+	if cursor := c.Query("draftsCursor"); cursor != "" {
+		if draftsPage.after, err = decodeCursor(cursor); err != nil {
+			ResponseError(c, err)
+			return
+		}
 	}
 
-	err = db.Table("blessings").
-		Select("blessings.id, blessings.receiver_id, blessings.content, blessings.font, blessings.paper_style, blessings.created_at as timestamp").
-		Where("(blessings.sender_id = ?) and (blessings.receiver_id is null)", userID).
-		Order("blessings.created_at DESC").
-		Scan(&drafts).Error
-
+	blessings, next, err := a.blessings.Sent(userID, p)
 	if err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "无法获取草稿箱")
+		ResponseError(c, err)
+		return
+	}
+	drafts, draftsNext, err := a.blessings.Drafts(userID, draftsPage)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 
 	ResponseOK(c, gin.H{
-		"sent_blessings": blessings,
-		"草稿箱":            drafts,
+		"sent_blessings":   blessings,
+		"nextCursor":       next,
+		"草稿箱":              drafts,
+		"draftsNextCursor": draftsNext,
 	}, "查询祝福成功")
 }
 
 // 查询自己收到的祝福
-func GetReceivedBlessings(c *gin.Context) {
+func (a *App) GetReceivedBlessings(c *gin.Context) {
 	userID := c.GetInt("userID")
-
-	var blessings []struct {
-		ID         int    `json:"id"`
-		SenderID   uint   `json:"sender_id"`
-		SenderName string `json:"sender_name"`
-		Content    string `json:"content"`
-		Font       string `json:"font"`
-		PaperStyle string `json:"paper_style"`
-		Timestamp  string `json:"timestamp"`
+	p, err := bindPage(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
 	}
 
-	err := db.Table("blessings").
-		Select("blessings.id, blessings.sender_id, users.user_name as sender_name, blessings.content, blessings.font, blessings.paper_style, blessings.created_at as timestamp").
-		Joins("JOIN users ON blessings.sender_id = users.id").
-		Where("blessings.receiver_id = ?", userID).
-		Order("blessings.created_at DESC").
-		Scan(&blessings).Error
-
+	blessings, next, err := a.blessings.Received(userID, p)
 	if err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "无法获取收到的祝福")
+		ResponseError(c, err)
 		return
 	}
 
 	ResponseOK(c, gin.H{
 		"received_blessings": blessings,
+		"nextCursor":         next,
 	}, "查询成功")
 }
 
-func ShareBlessings(c *gin.Context) {
-	var req struct {
-		ReceiverID *int   `json:"receiver_id"`
-		Content    string `json:"content"`
-		Font       string `json:"font"`
-		PaperStyle string `json:"paper_style"`
-	}
-
+func (a *App) ShareBlessings(c *gin.Context) {
+	var req BlessingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "Invalid request format")
+		ResponseError(c, invalidRequest(err))
 		return
 	}
 
 	senderID := c.GetInt("userID")
 
-	blessing := Blessing{
-		SenderID:   senderID,
-		ReceiverID: nil,
-		Content:    req.Content,
-		Font:       req.Font,
-		PaperStyle: req.PaperStyle,
-	}
-
-	if err := db.Create(&blessing).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "分享祝福失败")
+	blessing, err := a.blessings.Share(senderID, req)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 
@@ -174,23 +289,17 @@ func ShareBlessings(c *gin.Context) {
 	}, "您可以以此id分享祝福")
 }
 
-func ReceiveByLink(c *gin.Context) {
-	blessingID := c.Query("id")
-	userID := c.GetInt("userID")
-
-	var blessing Blessing
-	if err := db.First(&blessing, blessingID).Error; err != nil {
-		ResponseFAIL(c, http.StatusNotFound, "没有查询到这条祝福")
+func (a *App) ReceiveByLink(c *gin.Context) {
+	blessingID, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		ResponseError(c, ErrBlessingNotFound)
+		return
 	}
+	userID := c.GetInt("userID")
 
-	if blessing.ReceiverID == nil {
-		blessing.ReceiverID = &userID
-		if err := db.Save(&blessing).Error; err != nil {
-			ResponseFAIL(c, http.StatusInternalServerError, "接收祝福失败")
-			return
-		}
-	} else if *blessing.ReceiverID != userID {
-		ResponseFAIL(c, http.StatusForbidden, "您无权查看已发送给别人的祝福")
+	blessing, err := a.blessings.Receive(userID, blessingID)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 
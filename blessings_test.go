@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSendBlessings(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	carolID, _ := ts.signup("carol")
+	ts.befriend(alice, bob, bobID)
+
+	blessing := gin.H{"content": "新春快乐", "font": "kaiti", "paper_style": "red"}
+
+	blessing["receiver_id"] = carolID
+	expectCode(t, ts.do(http.MethodPost, "/blessings", alice, blessing), http.StatusForbidden)
+
+	blessing["receiver_id"] = bobID
+	expectOK(t, ts.do(http.MethodPost, "/blessings", alice, blessing))
+
+	delete(blessing, "receiver_id")
+	expectOK(t, ts.do(http.MethodPost, "/blessings", alice, blessing))
+
+	var sent struct {
+		Sent []struct {
+			ReceiverID   int    `json:"receiver_id"`
+			ReceiverName string `json:"receiver_name"`
+		} `json:"sent_blessings"`
+		Drafts []struct {
+			ReceiverID *int `json:"receiver_id"`
+		} `json:"草稿箱"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/blessings/sent", alice, nil), &sent)
+	if len(sent.Sent) != 1 || sent.Sent[0].ReceiverID != bobID || sent.Sent[0].ReceiverName != "bob" {
+		t.Fatalf("发出的祝福不符: %+v", sent.Sent)
+	}
+	if len(sent.Drafts) != 1 || sent.Drafts[0].ReceiverID != nil {
+		t.Fatalf("草稿箱不符: %+v", sent.Drafts)
+	}
+
+	var received struct {
+		Received []struct {
+			SenderName string `json:"sender_name"`
+			Content    string `json:"content"`
+		} `json:"received_blessings"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/blessings/received", bob, nil), &received)
+	if len(received.Received) != 1 || received.Received[0].SenderName != "alice" || received.Received[0].Content != "新春快乐" {
+		t.Fatalf("收到的祝福不符: %+v", received.Received)
+	}
+}
+
+func TestShareAndReceiveByLink(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	_, carol := ts.signup("carol")
+
+	var shared struct {
+		BlessingID int `json:"blessing_id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, "/blessings/share", alice, gin.H{"content": "端午安康", "font": "song", "paper_style": "green"}), &shared)
+	link := "/blessings/get?id=" + strconv.Itoa(shared.BlessingID)
+
+	var got struct {
+		SenderID   int  `json:"sender_id"`
+		ReceiverID *int `json:"receiver_id"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, link, bob, nil), &got)
+	if got.SenderID != aliceID || got.ReceiverID == nil || *got.ReceiverID != bobID {
+		t.Fatalf("领取结果不符: %+v", got)
+	}
+
+	// 领取人可以重复查看，其他人无权查看
+	expectOK(t, ts.do(http.MethodGet, link, bob, nil))
+	expectCode(t, ts.do(http.MethodGet, link, carol, nil), http.StatusForbidden)
+
+	// 不存在的祝福只返回一次 404，不会继续被当作空祝福领取
+	resp := ts.do(http.MethodGet, "/blessings/get?id=999", carol, nil)
+	expectCode(t, resp, http.StatusNotFound)
+	var count int64
+	ts.db.Model(&Blessing{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("不应创建新的祝福，实际共 %d 条", count)
+	}
+}
@@ -29,8 +29,8 @@ type CommentView struct {
 
 type CommentLike struct {
 	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	CommentID int       `gorm:"not null" json:"commentId"`
-	UserID    int       `gorm:"not null" json:"userId"`
+	CommentID int       `gorm:"not null;uniqueIndex:idx_comment_likes_comment_user" json:"commentId"`
+	UserID    int       `gorm:"not null;uniqueIndex:idx_comment_likes_comment_user" json:"userId"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 }
 
@@ -40,32 +40,160 @@ type CreateCommentRequest struct {
 	ParentID *int   `json:"parentId"` // 父评论ID（可选）
 }
 
+var (
+	ErrInvalidCommentID = newAppError("INVALID_COMMENT_ID", http.StatusBadRequest, "评论ID无效", "Invalid comment id")
+	ErrCommentNotFound  = newAppError("COMMENT_NOT_FOUND", http.StatusNotFound, "评论不存在", "Comment not found")
+)
+
+// 评论领域服务
+type CommentService struct {
+	db   *gorm.DB
+	load *Loader
+}
+
+func NewCommentService(db *gorm.DB) *CommentService {
+	return &CommentService{db: db, load: NewLoader(db)}
+}
+
+func (s *CommentService) Create(userID, postID int, req CreateCommentRequest) (*Comment, error) {
+	if err := checkPostVisible(s.db, userID, postID); err != nil {
+		return nil, err
+	}
+	comment := Comment{
+		PostID:   postID,
+		UserID:   userID,
+		Content:  req.Content,
+		ParentID: req.ParentID, // 父评论ID
+	}
+	if err := s.db.Create(&comment).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// 检查评论存在且所在的说说对查看者可见，否则返回 ErrCommentNotFound
+func (s *CommentService) checkVisible(viewerID, commentID int) error {
+	var count int64
+	err := s.db.Model(&Comment{}).
+		Joins("JOIN posts ON posts.id = comments.post_id").
+		Where("comments.id = ?", commentID).
+		Where(postVisibleTo(s.db, viewerID)).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+func (s *CommentService) Like(userID, commentID int) error {
+	if err := s.checkVisible(userID, commentID); err != nil {
+		return err
+	}
+	// 检查是否已经点赞
+	var like CommentLike
+	if err := s.db.Where("comment_id = ? AND user_id = ?", commentID, userID).First(&like).Error; err == nil {
+		return ErrAlreadyLiked
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 创建点赞记录
+		like = CommentLike{
+			CommentID: commentID,
+			UserID:    userID,
+		}
+		if err := tx.Create(&like).Error; err != nil {
+			return err
+		}
+		// 更新点赞数
+		return tx.Model(&Comment{}).Where("id = ?", commentID).Update("like_count", gorm.Expr("like_count + 1")).Error
+	})
+}
+
+// 删除评论（软删除），回复保留
+func (s *CommentService) Delete(commentID int) error {
+	res := s.db.Delete(&Comment{}, commentID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+func (s *CommentService) Unlike(userID, commentID int) error {
+	if err := s.checkVisible(userID, commentID); err != nil {
+		return err
+	}
+	// 检查是否已经点赞
+	var like CommentLike
+	if err := s.db.Where("comment_id = ? AND user_id = ?", commentID, userID).First(&like).Error; err != nil {
+		return ErrNotLiked
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 删除点赞记录
+		if err := tx.Where("comment_id = ? AND user_id = ?", commentID, userID).Delete(&CommentLike{}).Error; err != nil {
+			return err
+		}
+		// 更新点赞数
+		return tx.Model(&Comment{}).Where("id = ?", commentID).Update("like_count", gorm.Expr("like_count - 1")).Error
+	})
+}
+
+// 按时间倒序分页查询某条说说下的评论，并标记当前用户是否已点赞
+func (s *CommentService) List(viewerID, postID int, p page) ([]CommentView, string, error) {
+	if err := checkPostVisible(s.db, viewerID, postID); err != nil {
+		return nil, "", err
+	}
+	comments := []CommentView{}
+	query := s.db.Table("comments").
+		Select("comments.*, users.nick_name").
+		Where("comments.post_id = ?", postID).
+		Joins("LEFT JOIN users ON comments.user_id = users.id").
+		Where("users.deleted_at IS NULL")
+	if err := p.apply(query, "comments.created_at", "comments.id").Find(&comments).Error; err != nil {
+		return nil, "", err
+	}
+	comments, next := pageResult(comments, p, func(c CommentView) (time.Time, int) { return c.CreatedAt, c.ID })
+
+	ids := make([]int, len(comments))
+	for i := range comments {
+		ids[i] = comments[i].ID
+	}
+	liked, err := s.load.LikedComments(viewerID, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range comments {
+		comments[i].IsLiked = liked[comments[i].ID]
+	}
+	return comments, next, nil
+}
+
 // 发布评论
-func createCommentHandler(c *gin.Context, db *gorm.DB) {
+func (a *App) createCommentHandler(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
 	postIDStr := c.Param("id")
 
 	postID, err := strconv.Atoi(postIDStr)
 	if err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "帖子ID无效")
+		ResponseError(c, ErrInvalidPostID)
 		return
 	}
 
 	var req CreateCommentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, err.Error())
+		ResponseError(c, invalidRequest(err))
 		return
 	}
 
-	comment := Comment{
-		PostID:   postID,
-		UserID:   userID,
-		Content:  req.Content,
-		ParentID: req.ParentID, // 父评论ID
-	}
-
-	if err := db.Create(&comment).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, err.Error())
+	comment, err := a.comments.Create(userID, postID, req)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 
@@ -78,99 +206,55 @@ func createCommentHandler(c *gin.Context, db *gorm.DB) {
 }
 
 // 点赞评论
-func likeCommentHandler(c *gin.Context, db *gorm.DB) {
+func (a *App) likeCommentHandler(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
 	commentIDStr := c.Param("id")
 
 	commentID, err := strconv.Atoi(commentIDStr)
 	if err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "评论ID无效")
+		ResponseError(c, ErrInvalidCommentID)
 		return
 	}
 
-	// 检查是否已经点赞
-	var like CommentLike
-	if err := db.Where("comment_id = ? AND user_id = ?", commentID, userID).First(&like).Error; err == nil {
-		ResponseFAIL(c, http.StatusBadRequest, "已经点赞")
-		return
-	}
-
-	// 创建点赞记录
-	like = CommentLike{
-		CommentID: commentID,
-		UserID:    userID,
-	}
-	if err := db.Create(&like).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "点赞失败")
+	if err := a.comments.Like(userID, commentID); err != nil {
+		ResponseError(c, err)
 		return
 	}
-
-	// 更新点赞数
-	if err := db.Model(&Comment{}).Where("id = ?", commentID).Update("like_count", gorm.Expr("like_count + 1")).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "更新点赞数失败")
-		return
-	}
-
 	ResponseOK(c, nil, "点赞成功")
 }
 
 // 取消点赞评论
-func unlikeCommentHandler(c *gin.Context, db *gorm.DB) {
+func (a *App) unlikeCommentHandler(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
 	commentIDStr := c.Param("id")
 
 	commentID, err := strconv.Atoi(commentIDStr)
 	if err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "评论ID无效")
-		return
-	}
-
-	// 检查是否已经点赞
-	var like CommentLike
-	if err := db.Where("comment_id = ? AND user_id = ?", commentID, userID).First(&like).Error; err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "不存在点赞记录，无法取消点赞")
-		return
-	}
-
-	// 删除点赞记录
-	if err := db.Where("comment_id = ? AND user_id = ?", commentID, userID).Delete(&CommentLike{}).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "取消点赞失败")
+		ResponseError(c, ErrInvalidCommentID)
 		return
 	}
 
-	// 更新点赞数
-	if err := db.Model(&Comment{}).Where("id = ?", commentID).Update("like_count", gorm.Expr("like_count - 1")).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "更新点赞数失败")
+	if err := a.comments.Unlike(userID, commentID); err != nil {
+		ResponseError(c, err)
 		return
 	}
-
 	ResponseOK(c, nil, "取消点赞成功")
 }
 
 // 查询评论
-func getCommentsHandler(c *gin.Context, db *gorm.DB) {
+func (a *App) getCommentsHandler(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
-	postID := c.Param("id")
-
-	var comments []CommentView
-	if err := db.Table("comments").
-		Select("comments.*, users.nick_name").
-		Where("post_id = ?", postID).
-		Joins("LEFT JOIN users ON comments.user_id = users.id").
-		Order("created_at DESC").
-		Find(&comments).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, err.Error())
+	postID, _ := strconv.Atoi(c.Param("id"))
+	p, err := bindPage(c)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 
-	for i := range comments {
-		var like CommentLike
-		if err := db.Where("comment_id = ? AND user_id = ?", comments[i].ID, userID).First(&like).Error; err == nil {
-			comments[i].IsLiked = true
-		} else {
-			comments[i].IsLiked = false
-		}
+	comments, next, err := a.comments.List(userID, postID, p)
+	if err != nil {
+		ResponseError(c, err)
+		return
 	}
-
-	ResponseOK(c, comments, "查询评论成功")
+	ResponseOK(c, gin.H{"comments": comments, "nextCursor": next}, "查询评论成功")
 }
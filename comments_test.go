@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCommentsAndCommentLikes(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	_, bob := ts.signup("bob")
+	postPath := "/posts/" + strconv.Itoa(ts.createPost(alice, "元宵快乐")) + "/comments"
+
+	var root struct {
+		ID int `json:"id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, postPath, bob, gin.H{"content": "同乐"}), &root)
+	expectOK(t, ts.do(http.MethodPost, postPath, alice, gin.H{"content": "谢谢", "parentId": root.ID}))
+	expectCode(t, ts.do(http.MethodPost, "/posts/abc/comments", bob, gin.H{"content": "x"}), http.StatusBadRequest)
+
+	commentPath := "/comments/" + strconv.Itoa(root.ID)
+	expectOK(t, ts.do(http.MethodPost, commentPath+"/like", alice, nil))
+	expectCode(t, ts.do(http.MethodPost, commentPath+"/like", alice, nil), http.StatusConflict)
+	expectError(t, ts.do(http.MethodPost, "/comments/abc/like", alice, nil), "INVALID_COMMENT_ID")
+
+	var page struct {
+		Comments []CommentView `json:"comments"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, postPath, alice, nil), &page)
+	comments := page.Comments
+	if len(comments) != 2 {
+		t.Fatalf("期望 2 条评论，实际 %d", len(comments))
+	}
+	for _, cm := range comments {
+		if cm.ID == root.ID && (!cm.IsLiked || cm.LikeCount != 1) {
+			t.Fatalf("评论应被点赞一次: %+v", cm)
+		}
+		if cm.ID != root.ID && (cm.ParentID == nil || *cm.ParentID != root.ID) {
+			t.Fatalf("回复的父评论不符: %+v", cm)
+		}
+	}
+
+	expectOK(t, ts.do(http.MethodPost, commentPath+"/unlike", alice, nil))
+	expectCode(t, ts.do(http.MethodPost, commentPath+"/unlike", alice, nil), http.StatusConflict)
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 默认的 JWT 密钥，仅作占位使用，启动时会拒绝该值
+const defaultJWTSecret = "your_secret_key"
+
+// 应用配置
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Redis     RedisConfig     `yaml:"redis"`
+	JWT       JWTConfig       `yaml:"jwt"`
+	Upload    UploadConfig    `yaml:"upload"`
+	CORS      CORSConfig      `yaml:"cors"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Lockout   LockoutConfig   `yaml:"lockout"`
+	Mail      MailConfig      `yaml:"mail"`
+	Reset     ResetConfig     `yaml:"password_reset"`
+	Policy    PolicyConfig    `yaml:"policy"`
+	TwoFactor TwoFactorConfig `yaml:"two_factor"`
+	Account   AccountConfig   `yaml:"account"`
+	Export    ExportConfig    `yaml:"export"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr"`              // 监听地址
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`  // 优雅关闭的最长等待时间
+	RealStatusCodes bool          `yaml:"real_status_codes"` // 错误响应使用真实的 HTTP 状态码，默认恒为 200
+}
+
+type DatabaseConfig struct {
+	Driver         string `yaml:"driver"` // mysql 或 sqlite
+	DSN            string `yaml:"dsn"`
+	MigrateOnStart bool   `yaml:"migrate_on_start"` // 启动时自动执行未完成的迁移
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+type JWTConfig struct {
+	Secret     string        `yaml:"secret"`
+	TTL        time.Duration `yaml:"ttl"`         // 访问令牌有效期
+	RefreshTTL time.Duration `yaml:"refresh_ttl"` // 刷新令牌（登录会话）有效期，每次刷新后重新计算
+}
+
+type UploadConfig struct {
+	Dir string `yaml:"dir"` // 头像等上传文件的存储目录
+}
+
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins"`
+}
+
+// 各路由的限流规则
+type RateLimitConfig struct {
+	Login          RateRule `yaml:"login"`           // 按 IP 限制登录
+	LoginAccount   RateRule `yaml:"login_account"`   // 按用户名限制登录
+	Register       RateRule `yaml:"register"`        // 按 IP 限制注册
+	PasswordForgot RateRule `yaml:"password_forgot"` // 按 IP 限制找回密码邮件
+	RegisterCheck  RateRule `yaml:"register_check"`  // 按 IP 限制用户名可用性查询
+	UserSearch     RateRule `yaml:"user_search"`     // 按 IP 限制用户搜索
+}
+
+// 滑动窗口限流规则：Window 内最多 Limit 次请求，Limit 为 0 时不限流
+type RateRule struct {
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+}
+
+// 解析 "次数/时长" 格式的限流规则，如 10/1m
+func parseRateRule(s string) (RateRule, error) {
+	limit, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateRule{}, fmt.Errorf("限流规则格式应为 次数/时长: %q", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil {
+		return RateRule{}, fmt.Errorf("限流次数无效: %q", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil {
+		return RateRule{}, fmt.Errorf("限流时长无效: %q", s)
+	}
+	return RateRule{Limit: n, Window: d}, nil
+}
+
+// 注册时的用户名和密码策略
+type PolicyConfig struct {
+	UserName UserNamePolicy `yaml:"user_name"`
+	Password PasswordPolicy `yaml:"password"`
+}
+
+type UserNamePolicy struct {
+	MinLength int      `yaml:"min_length"`
+	MaxLength int      `yaml:"max_length"`
+	Reserved  []string `yaml:"reserved"` // 保留的用户名，不区分大小写
+}
+
+type PasswordPolicy struct {
+	MinLength    int    `yaml:"min_length"`
+	MinClasses   int    `yaml:"min_classes"`   // 至少包含的字符类别数（小写、大写、数字、符号）
+	BreachedFile string `yaml:"breached_file"` // 已泄露的常见密码列表，每行一个，为空时不检查
+}
+
+// 邮件发送配置
+type MailConfig struct {
+	Driver string     `yaml:"driver"` // log（默认，仅用于开发，只记录收件人和主题）、file（写入 Dir 目录）或 smtp
+	From   string     `yaml:"from"`
+	Dir    string     `yaml:"dir"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+const (
+	MailDriverLog  = "log"
+	MailDriverFile = "file"
+	MailDriverSMTP = "smtp"
+)
+
+// 找回密码配置
+type ResetConfig struct {
+	TTL time.Duration `yaml:"ttl"` // 重置令牌有效期
+	URL string        `yaml:"url"` // 邮件中的重置链接，令牌会拼接在末尾
+}
+
+// 两步验证配置
+type TwoFactorConfig struct {
+	Issuer       string        `yaml:"issuer"`        // 验证器应用中显示的服务名称
+	ChallengeTTL time.Duration `yaml:"challenge_ttl"` // 密码验证通过后输入验证码的时限
+}
+
+// 注销账户配置
+type AccountConfig struct {
+	DeletionGrace time.Duration `yaml:"deletion_grace"` // 注销后可以恢复的时间，到期后永久清除数据
+	PurgeInterval time.Duration `yaml:"purge_interval"` // 后台检查到期账户的间隔
+}
+
+// 个人数据导出配置
+type ExportConfig struct {
+	Dir string        `yaml:"dir"` // 导出文件的存储目录
+	TTL time.Duration `yaml:"ttl"` // 导出文件生成后的保留时间
+}
+
+// 连续登录失败后的账户锁定策略：每累计 Threshold 次失败锁定一次，
+// 锁定时长从 Duration 开始逐次翻倍，最长 MaxDuration
+type LockoutConfig struct {
+	Threshold   int           `yaml:"threshold"` // 为 0 时不锁定
+	Duration    time.Duration `yaml:"duration"`
+	MaxDuration time.Duration `yaml:"max_duration"`
+}
+
+// 默认配置，与原先硬编码的值保持一致
+func DefaultConfig() Config {
+	return Config{
+		Server: ServerConfig{Addr: ":8080", ShutdownTimeout: 15 * time.Second},
+		Database: DatabaseConfig{
+			Driver:         DriverMySQL,
+			DSN:            "root:123456@tcp(127.0.0.1:3306)/festival_blessing?charset=utf8mb4&parseTime=True&loc=Local",
+			MigrateOnStart: true,
+		},
+		Redis:  RedisConfig{Addr: "127.0.0.1:6379"},
+		JWT:    JWTConfig{Secret: defaultJWTSecret, TTL: 15 * time.Minute, RefreshTTL: 30 * 24 * time.Hour},
+		Upload: UploadConfig{Dir: "avatar"},
+		CORS:   CORSConfig{AllowOrigins: []string{"*"}},
+		RateLimit: RateLimitConfig{
+			Login:          RateRule{Limit: 20, Window: time.Minute},
+			LoginAccount:   RateRule{Limit: 10, Window: time.Minute},
+			Register:       RateRule{Limit: 10, Window: time.Hour},
+			PasswordForgot: RateRule{Limit: 5, Window: time.Hour},
+			RegisterCheck:  RateRule{Limit: 60, Window: time.Minute},
+			UserSearch:     RateRule{Limit: 60, Window: time.Minute},
+		},
+		Lockout: LockoutConfig{Threshold: 5, Duration: time.Minute, MaxDuration: time.Hour},
+		Mail:    MailConfig{Driver: MailDriverLog, From: "no-reply@festival-blessing.local", Dir: "mail", SMTP: SMTPConfig{Port: 587}},
+		Reset:   ResetConfig{TTL: 30 * time.Minute, URL: "http://localhost:8080/password/reset?token="},
+		Policy: PolicyConfig{
+			UserName: UserNamePolicy{
+				MinLength: 3,
+				MaxLength: 32,
+				Reserved:  []string{"admin", "administrator", "root", "system", "support", "official", "null", "undefined"},
+			},
+			Password: PasswordPolicy{MinLength: 8, MinClasses: 2},
+		},
+		TwoFactor: TwoFactorConfig{Issuer: "festival-blessing", ChallengeTTL: 5 * time.Minute},
+		Account:   AccountConfig{DeletionGrace: 30 * 24 * time.Hour, PurgeInterval: time.Hour},
+		Export:    ExportConfig{Dir: "exports", TTL: 24 * time.Hour},
+	}
+}
+
+// 加载配置：默认值 <- 配置文件 <- 环境变量，最后进行校验
+// path 为空或文件不存在时只使用默认值和环境变量
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(data, &cfg); err != nil {
+				return cfg, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+			}
+		case errors.Is(err, os.ErrNotExist):
+		default:
+			return cfg, fmt.Errorf("读取配置文件 %s 失败: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// 使用环境变量覆盖配置
+func (cfg *Config) applyEnv(lookup func(string) (string, bool)) error {
+	if v, ok := lookup("FB_LISTEN_ADDR"); ok {
+		cfg.Server.Addr = v
+	}
+	if v, ok := lookup("FB_SHUTDOWN_TIMEOUT"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_SHUTDOWN_TIMEOUT 不是有效的时长: %q", v)
+		}
+		cfg.Server.ShutdownTimeout = d
+	}
+	if v, ok := lookup("FB_REAL_STATUS_CODES"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("FB_REAL_STATUS_CODES 不是有效的布尔值: %q", v)
+		}
+		cfg.Server.RealStatusCodes = b
+	}
+	if v, ok := lookup("FB_DB_DRIVER"); ok {
+		cfg.Database.Driver = v
+	}
+	if v, ok := lookup("FB_DB_DSN"); ok {
+		cfg.Database.DSN = v
+	}
+	if v, ok := lookup("FB_DB_MIGRATE_ON_START"); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("FB_DB_MIGRATE_ON_START 不是有效的布尔值: %q", v)
+		}
+		cfg.Database.MigrateOnStart = b
+	}
+	if v, ok := lookup("FB_REDIS_ADDR"); ok {
+		cfg.Redis.Addr = v
+	}
+	if v, ok := lookup("FB_REDIS_PASSWORD"); ok {
+		cfg.Redis.Password = v
+	}
+	if v, ok := lookup("FB_REDIS_DB"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("FB_REDIS_DB 不是有效的整数: %q", v)
+		}
+		cfg.Redis.DB = n
+	}
+	if v, ok := lookup("FB_JWT_SECRET"); ok {
+		cfg.JWT.Secret = v
+	}
+	if v, ok := lookup("FB_JWT_TTL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_JWT_TTL 不是有效的时长: %q", v)
+		}
+		cfg.JWT.TTL = d
+	}
+	if v, ok := lookup("FB_JWT_REFRESH_TTL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_JWT_REFRESH_TTL 不是有效的时长: %q", v)
+		}
+		cfg.JWT.RefreshTTL = d
+	}
+	if v, ok := lookup("FB_UPLOAD_DIR"); ok {
+		cfg.Upload.Dir = v
+	}
+	if v, ok := lookup("FB_CORS_ORIGINS"); ok {
+		cfg.CORS.AllowOrigins = splitList(v)
+	}
+	rules := map[string]*RateRule{
+		"FB_RATE_LIMIT_LOGIN":           &cfg.RateLimit.Login,
+		"FB_RATE_LIMIT_LOGIN_ACCOUNT":   &cfg.RateLimit.LoginAccount,
+		"FB_RATE_LIMIT_REGISTER":        &cfg.RateLimit.Register,
+		"FB_RATE_LIMIT_PASSWORD_FORGOT": &cfg.RateLimit.PasswordForgot,
+		"FB_RATE_LIMIT_REGISTER_CHECK":  &cfg.RateLimit.RegisterCheck,
+		"FB_RATE_LIMIT_USER_SEARCH":     &cfg.RateLimit.UserSearch,
+	}
+	for name, rule := range rules {
+		if v, ok := lookup(name); ok {
+			r, err := parseRateRule(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*rule = r
+		}
+	}
+	if v, ok := lookup("FB_LOCKOUT_THRESHOLD"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("FB_LOCKOUT_THRESHOLD 不是有效的整数: %q", v)
+		}
+		cfg.Lockout.Threshold = n
+	}
+	if v, ok := lookup("FB_LOCKOUT_DURATION"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_LOCKOUT_DURATION 不是有效的时长: %q", v)
+		}
+		cfg.Lockout.Duration = d
+	}
+	if v, ok := lookup("FB_LOCKOUT_MAX_DURATION"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_LOCKOUT_MAX_DURATION 不是有效的时长: %q", v)
+		}
+		cfg.Lockout.MaxDuration = d
+	}
+	if v, ok := lookup("FB_MAIL_DRIVER"); ok {
+		cfg.Mail.Driver = v
+	}
+	if v, ok := lookup("FB_MAIL_FROM"); ok {
+		cfg.Mail.From = v
+	}
+	if v, ok := lookup("FB_MAIL_DIR"); ok {
+		cfg.Mail.Dir = v
+	}
+	if v, ok := lookup("FB_SMTP_HOST"); ok {
+		cfg.Mail.SMTP.Host = v
+	}
+	if v, ok := lookup("FB_SMTP_PORT"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("FB_SMTP_PORT 不是有效的整数: %q", v)
+		}
+		cfg.Mail.SMTP.Port = n
+	}
+	if v, ok := lookup("FB_SMTP_USERNAME"); ok {
+		cfg.Mail.SMTP.Username = v
+	}
+	if v, ok := lookup("FB_SMTP_PASSWORD"); ok {
+		cfg.Mail.SMTP.Password = v
+	}
+	if v, ok := lookup("FB_PASSWORD_RESET_TTL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_PASSWORD_RESET_TTL 不是有效的时长: %q", v)
+		}
+		cfg.Reset.TTL = d
+	}
+	if v, ok := lookup("FB_PASSWORD_RESET_URL"); ok {
+		cfg.Reset.URL = v
+	}
+	if v, ok := lookup("FB_USERNAME_RESERVED"); ok {
+		cfg.Policy.UserName.Reserved = splitList(v)
+	}
+	if v, ok := lookup("FB_PASSWORD_MIN_LENGTH"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("FB_PASSWORD_MIN_LENGTH 不是有效的整数: %q", v)
+		}
+		cfg.Policy.Password.MinLength = n
+	}
+	if v, ok := lookup("FB_PASSWORD_MIN_CLASSES"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("FB_PASSWORD_MIN_CLASSES 不是有效的整数: %q", v)
+		}
+		cfg.Policy.Password.MinClasses = n
+	}
+	if v, ok := lookup("FB_PASSWORD_BREACHED_FILE"); ok {
+		cfg.Policy.Password.BreachedFile = v
+	}
+	if v, ok := lookup("FB_2FA_ISSUER"); ok {
+		cfg.TwoFactor.Issuer = v
+	}
+	if v, ok := lookup("FB_2FA_CHALLENGE_TTL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_2FA_CHALLENGE_TTL 不是有效的时长: %q", v)
+		}
+		cfg.TwoFactor.ChallengeTTL = d
+	}
+	if v, ok := lookup("FB_ACCOUNT_DELETION_GRACE"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_ACCOUNT_DELETION_GRACE 不是有效的时长: %q", v)
+		}
+		cfg.Account.DeletionGrace = d
+	}
+	if v, ok := lookup("FB_ACCOUNT_PURGE_INTERVAL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_ACCOUNT_PURGE_INTERVAL 不是有效的时长: %q", v)
+		}
+		cfg.Account.PurgeInterval = d
+	}
+	if v, ok := lookup("FB_EXPORT_DIR"); ok {
+		cfg.Export.Dir = v
+	}
+	if v, ok := lookup("FB_EXPORT_TTL"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("FB_EXPORT_TTL 不是有效的时长: %q", v)
+		}
+		cfg.Export.TTL = d
+	}
+	return nil
+}
+
+// 校验配置，返回所有不合法的项
+func (cfg Config) Validate() error {
+	var errs []error
+	if cfg.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr 不能为空"))
+	}
+	if cfg.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout 必须大于 0"))
+	}
+	if cfg.Database.Driver != DriverMySQL && cfg.Database.Driver != DriverSQLite {
+		errs = append(errs, fmt.Errorf("database.driver 只能是 %s 或 %s", DriverMySQL, DriverSQLite))
+	}
+	if cfg.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn 不能为空"))
+	}
+	if cfg.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr 不能为空"))
+	}
+	if cfg.Redis.DB < 0 {
+		errs = append(errs, errors.New("redis.db 不能为负数"))
+	}
+	switch {
+	case cfg.JWT.Secret == "":
+		errs = append(errs, errors.New("jwt.secret 不能为空"))
+	case cfg.JWT.Secret == defaultJWTSecret:
+		errs = append(errs, errors.New("jwt.secret 仍为默认值，请通过配置文件或 FB_JWT_SECRET 设置"))
+	}
+	if cfg.JWT.TTL <= 0 {
+		errs = append(errs, errors.New("jwt.ttl 必须大于 0"))
+	}
+	if cfg.JWT.RefreshTTL < cfg.JWT.TTL {
+		errs = append(errs, errors.New("jwt.refresh_ttl 不能小于 jwt.ttl"))
+	}
+	if cfg.Upload.Dir == "" {
+		errs = append(errs, errors.New("upload.dir 不能为空"))
+	}
+	if len(cfg.CORS.AllowOrigins) == 0 {
+		errs = append(errs, errors.New("cors.allow_origins 至少需要一项"))
+	}
+	for _, r := range []struct {
+		name string
+		rule RateRule
+	}{
+		{"login", cfg.RateLimit.Login},
+		{"login_account", cfg.RateLimit.LoginAccount},
+		{"register", cfg.RateLimit.Register},
+		{"password_forgot", cfg.RateLimit.PasswordForgot},
+		{"register_check", cfg.RateLimit.RegisterCheck},
+		{"user_search", cfg.RateLimit.UserSearch},
+	} {
+		if r.rule.Limit < 0 || (r.rule.Limit > 0 && r.rule.Window <= 0) {
+			errs = append(errs, fmt.Errorf("rate_limit.%s 的 limit 不能为负数，启用时 window 必须大于 0", r.name))
+		}
+	}
+	if cfg.Lockout.Threshold < 0 {
+		errs = append(errs, errors.New("lockout.threshold 不能为负数"))
+	}
+	if cfg.Lockout.Threshold > 0 && (cfg.Lockout.Duration <= 0 || cfg.Lockout.MaxDuration < cfg.Lockout.Duration) {
+		errs = append(errs, errors.New("lockout.duration 必须大于 0 且不能大于 lockout.max_duration"))
+	}
+	switch cfg.Mail.Driver {
+	case MailDriverLog:
+	case MailDriverFile:
+		if cfg.Mail.Dir == "" {
+			errs = append(errs, errors.New("mail.driver 为 file 时 mail.dir 不能为空"))
+		}
+	case MailDriverSMTP:
+		if cfg.Mail.SMTP.Host == "" || cfg.Mail.SMTP.Port <= 0 {
+			errs = append(errs, errors.New("mail.driver 为 smtp 时必须设置 mail.smtp.host 和 mail.smtp.port"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("不支持的 mail.driver %q，可选 log、file、smtp", cfg.Mail.Driver))
+	}
+	if cfg.Mail.From == "" {
+		errs = append(errs, errors.New("mail.from 不能为空"))
+	}
+	if cfg.Reset.TTL <= 0 {
+		errs = append(errs, errors.New("password_reset.ttl 必须大于 0"))
+	}
+	if un := cfg.Policy.UserName; un.MinLength < 1 || un.MaxLength < un.MinLength || un.MaxLength > 100 {
+		errs = append(errs, errors.New("policy.user_name 的长度范围无效，需满足 1 <= min_length <= max_length <= 100"))
+	}
+	if pw := cfg.Policy.Password; pw.MinLength < 1 || pw.MinLength > bcryptMaxBytes {
+		errs = append(errs, fmt.Errorf("policy.password.min_length 必须在 1 到 %d 之间", bcryptMaxBytes))
+	}
+	if pw := cfg.Policy.Password; pw.MinClasses < 0 || pw.MinClasses > 4 {
+		errs = append(errs, errors.New("policy.password.min_classes 必须在 0 到 4 之间"))
+	}
+	if cfg.TwoFactor.Issuer == "" || strings.Contains(cfg.TwoFactor.Issuer, ":") {
+		errs = append(errs, errors.New("two_factor.issuer 不能为空且不能包含冒号"))
+	}
+	if cfg.TwoFactor.ChallengeTTL <= 0 {
+		errs = append(errs, errors.New("two_factor.challenge_ttl 必须大于 0"))
+	}
+	if cfg.Account.DeletionGrace < 0 {
+		errs = append(errs, errors.New("account.deletion_grace 不能为负数"))
+	}
+	if cfg.Account.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("account.purge_interval 必须大于 0"))
+	}
+	if cfg.Export.Dir == "" {
+		errs = append(errs, errors.New("export.dir 不能为空"))
+	}
+	if cfg.Export.TTL <= 0 {
+		errs = append(errs, errors.New("export.ttl 必须大于 0"))
+	}
+	return errors.Join(errs...)
+}
+
+// 将逗号分隔的字符串拆分为列表，忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -1,52 +1,67 @@
 package main
 
 import (
+	"fmt"
+
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
-	"sync"
 )
 
-var db *gorm.DB
-var once sync.Once
-var dbErr error
+// 支持的数据库驱动
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+)
 
-func InitDb() *gorm.DB {
-	once.Do(func() {
-		dsn := "root:123456@tcp(127.0.0.1:3306)/festival_blessing?charset=utf8mb4&parseTime=True&loc=Local"
-		db, dbErr = gorm.Open(mysql.Open(dsn), &gorm.Config{})
-		if dbErr != nil {
-			panic("failed to connect database")
-		}
-	})
-	if err := db.AutoMigrate(&Avatar{}); err != nil {
-		panic(err)
-	}
-	if err := db.AutoMigrate(&Blessing{}); err != nil {
-		panic(err)
-	}
-	if err := db.AutoMigrate(&ChatMessage{}); err != nil {
-		panic(err)
-	}
-	if err := db.AutoMigrate(&CommentLike{}); err != nil {
-		panic(err)
+// 根据驱动打开数据库连接
+// MySQL 用于生产环境，SQLite 可使用文件或 ":memory:" 用于本地开发和测试
+func OpenDatabase(cfg DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case DriverMySQL:
+		dialector = mysql.Open(cfg.DSN)
+	case DriverSQLite:
+		dialector = sqlite.Open(cfg.DSN)
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %q", cfg.Driver)
 	}
-	if err := db.AutoMigrate(&Comment{}); err != nil {
-		panic(err)
+
+	conn, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
 	}
-	if err := db.AutoMigrate(&FriendRelationship{}); err != nil {
-		panic(err)
+	if cfg.Driver == DriverSQLite {
+		// SQLite 只允许单个写入者，内存数据库在每个连接上都是独立的，因此只保留一个连接
+		sqlDB, err := conn.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
 	}
-	if err := db.AutoMigrate(&FriendRequest{}); err != nil {
-		panic(err)
+	return conn, nil
+}
+
+// 打开数据库，并根据配置执行未完成的迁移；未开启自动迁移且存在待执行迁移时拒绝启动
+func InitDb(cfg DatabaseConfig) (*gorm.DB, error) {
+	db, err := OpenDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("连接数据库失败: %w", err)
 	}
-	if err := db.AutoMigrate(&Like{}); err != nil {
-		panic(err)
+
+	migrator := NewMigrator(db)
+	if cfg.MigrateOnStart {
+		if _, err := migrator.Up(); err != nil {
+			return nil, err
+		}
+		return db, nil
 	}
-	if err := db.AutoMigrate(&Post{}); err != nil {
-		panic(err)
+	pending, err := migrator.Pending()
+	if err != nil {
+		return nil, err
 	}
-	if err := db.AutoMigrate(&User{}); err != nil {
-		panic(err)
+	if pending > 0 {
+		return nil, fmt.Errorf("存在 %d 个未执行的迁移，请先执行 migrate up", pending)
 	}
-	return db
+	return db, nil
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// 字段详情中使用 json 标签名而不是 Go 字段名
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			// JSON 请求体使用 json 标签，查询参数使用 form 标签
+			for _, tag := range []string{"json", "form"} {
+				name := strings.SplitN(f.Tag.Get(tag), ",", 2)[0]
+				if name != "" && name != "-" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	}
+}
+
+// 应用错误：稳定的机器可读错误码、HTTP 状态码、本地化消息和可选的字段详情
+type AppError struct {
+	Code    string       // 错误码，如 BLESSING_NOT_FOUND，客户端应以此判断错误类型
+	Status  int          // HTTP 状态码
+	Message string       // 默认（中文）消息
+	Details []FieldError // 字段级错误详情
+	cause   error        // 内部原因，只写日志，不返回给客户端
+}
+
+// 字段级错误，Reason 为机器可读的原因，如 required、min
+type FieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e *AppError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.cause
+}
+
+// 错误码相同即视为同一种错误，便于 errors.Is 匹配附带了详情或原因的副本
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Code == e.Code
+}
+
+// 返回附带字段详情的副本
+func (e *AppError) WithDetails(details ...FieldError) *AppError {
+	cp := *e
+	cp.Details = append(append([]FieldError(nil), e.Details...), details...)
+	return &cp
+}
+
+// 返回附带内部原因的副本
+func (e *AppError) Wrap(cause error) *AppError {
+	cp := *e
+	cp.cause = cause
+	return &cp
+}
+
+// 按语言返回消息，目前支持 zh（默认）和 en
+func (e *AppError) Localize(lang string) string {
+	if lang == langEN {
+		if msg, ok := englishMessages[e.Code]; ok {
+			return msg
+		}
+	}
+	return e.Message
+}
+
+const (
+	langZH = "zh"
+	langEN = "en"
+)
+
+// 英文消息表，按错误码索引
+var englishMessages = map[string]string{}
+
+// 定义一个应用错误，同时登记英文消息
+func newAppError(code string, status int, zh, en string) *AppError {
+	englishMessages[code] = en
+	return &AppError{Code: code, Status: status, Message: zh}
+}
+
+// 通用错误
+var (
+	ErrInvalidRequest = newAppError("INVALID_REQUEST", http.StatusBadRequest, "请求参数无效", "Invalid request")
+	ErrTokenMissing   = newAppError("TOKEN_MISSING", http.StatusUnauthorized, "缺少认证令牌", "Missing authentication token")
+	ErrTokenInvalid   = newAppError("TOKEN_INVALID", http.StatusUnauthorized, "无效的令牌", "Invalid token")
+	ErrAuthUserGone   = newAppError("AUTH_USER_NOT_FOUND", http.StatusUnauthorized, "用户不存在", "User no longer exists")
+	ErrInternal       = newAppError("INTERNAL_ERROR", http.StatusInternalServerError, "服务器内部错误", "Internal server error")
+)
+
+// 将任意错误转换为应用错误，未知错误视为内部错误
+func asAppError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return ErrInternal.Wrap(err)
+}
+
+// 将请求绑定错误转换为带字段详情的参数错误
+func invalidRequest(err error) *AppError {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return ErrInvalidRequest.Wrap(err)
+	}
+	details := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		details = append(details, FieldError{Field: fe.Field(), Reason: fe.Tag()})
+	}
+	return ErrInvalidRequest.WithDetails(details...).Wrap(err)
+}
+
+// 根据 Accept-Language 选择语言
+func preferredLanguage(acceptLanguage string) string {
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, "zh"):
+			return langZH
+		case strings.HasPrefix(tag, "en"):
+			return langEN
+		}
+	}
+	return langZH
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAppErrorIs(t *testing.T) {
+	wrapped := fmt.Errorf("发送祝福: %w", ErrNotFriend.Wrap(errors.New("db")))
+	if !errors.Is(wrapped, ErrNotFriend) {
+		t.Fatal("附带原因的副本应与原错误匹配")
+	}
+	if errors.Is(wrapped, ErrBlessingNotFound) {
+		t.Fatal("不同错误码不应匹配")
+	}
+	if got := asAppError(errors.New("boom")); got.Code != ErrInternal.Code {
+		t.Fatalf("未知错误应视为内部错误，实际 %s", got.Code)
+	}
+}
+
+func TestErrorResponseLegacyStatus(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := ts.do(http.MethodGet, "/posts", "", nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("默认模式下 HTTP 状态应为 200，实际 %d", resp.Status)
+	}
+	expectCode(t, resp, http.StatusUnauthorized)
+	expectError(t, resp, "TOKEN_MISSING")
+}
+
+func TestErrorResponseRealStatus(t *testing.T) {
+	ts := newTestServer(t, func(cfg *Config) { cfg.Server.RealStatusCodes = true })
+	_, token := ts.signup("alice")
+
+	resp := ts.do(http.MethodGet, "/posts", "", nil)
+	if resp.Status != http.StatusUnauthorized || resp.Code != http.StatusUnauthorized {
+		t.Fatalf("期望 HTTP 401，实际 status=%d code=%d", resp.Status, resp.Code)
+	}
+	resp = ts.do(http.MethodPost, "/posts/abc/like", token, nil)
+	if resp.Status != http.StatusBadRequest {
+		t.Fatalf("期望 HTTP 400，实际 %d", resp.Status)
+	}
+	if resp = ts.do(http.MethodGet, "/posts", token, nil); resp.Status != http.StatusOK {
+		t.Fatalf("成功响应应为 200，实际 %d", resp.Status)
+	}
+}
+
+func TestErrorResponseDetailsAndLanguage(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := ts.do(http.MethodPost, "/register", "", gin.H{"userName": "bob"})
+	expectError(t, resp, "INVALID_REQUEST")
+	if len(resp.Error.Details) != 1 || resp.Error.Details[0] != (FieldError{Field: "password", Reason: "required"}) {
+		t.Fatalf("字段详情不符: %+v", resp.Error.Details)
+	}
+	// 查询参数使用 form 标签中的名称
+	_, alice := ts.signup("alice")
+	resp = ts.do(http.MethodGet, "/posts?limit=101", alice, nil)
+	expectError(t, resp, "INVALID_REQUEST")
+	if len(resp.Error.Details) != 1 || resp.Error.Details[0] != (FieldError{Field: "limit", Reason: "max"}) {
+		t.Fatalf("字段详情不符: %+v", resp.Error.Details)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.server.URL+"/profile?userID=999", nil)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	if resp := ts.send(req, ""); resp.Msg != "User not found" {
+		t.Fatalf("期望英文消息，实际 %q", resp.Msg)
+	}
+	if resp := ts.do(http.MethodGet, "/profile?userID=999", "", nil); resp.Msg != "用户不存在" {
+		t.Fatalf("默认应为中文消息，实际 %q", resp.Msg)
+	}
+}
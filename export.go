@@ -0,0 +1,475 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 导出状态
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+const (
+	exportQueueSize       = 64               // 等待生成的导出任务缓冲
+	exportCleanupInterval = 10 * time.Minute // 清理过期导出文件的间隔
+)
+
+var (
+	ErrExportNotFound = newAppError("EXPORT_NOT_FOUND", http.StatusNotFound, "导出记录不存在", "Export not found")
+	ErrExportNotReady = newAppError("EXPORT_NOT_READY", http.StatusConflict, "导出文件尚未生成，请稍后再试", "Export is not ready yet")
+	ErrExportFailed   = newAppError("EXPORT_FAILED", http.StatusInternalServerError, "导出失败，请重新申请", "Export failed, please request a new one")
+	ErrExportExpired  = newAppError("EXPORT_EXPIRED", http.StatusGone, "导出文件已过期，请重新申请", "Export has expired, please request a new one")
+	ErrExportBusy     = newAppError("EXPORT_BUSY", http.StatusServiceUnavailable, "导出任务繁忙，请稍后再试", "Too many exports in progress, please try again later")
+)
+
+// 个人数据导出记录
+type DataExport struct {
+	ID          int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int        `gorm:"not null;index" json:"-"`
+	Status      string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	FileName    string     `gorm:"type:varchar(255)" json:"-"` // 导出目录中的文件名
+	Size        int64      `json:"size"`
+	Error       string     `gorm:"type:varchar(255)" json:"-"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	CompletedAt *time.Time `json:"completedAt"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expiresAt"`
+}
+
+// 导出文件中的说说，包含已删除但尚未清除的说说
+type exportPost struct {
+	Post
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// 导出文件中的个人信息
+type exportProfile struct {
+	ID        int               `json:"id"`
+	UserName  string            `json:"userName"`
+	NickName  string            `json:"nickName"`
+	Age       int               `json:"age"`
+	Birthday  *Date             `json:"birthday"`
+	Gender    string            `json:"gender"`
+	Interests []string          `json:"interests"`
+	Status    string            `json:"status"`
+	Email     *string           `json:"email"`
+	Role      string            `json:"role"`
+	Privacy   map[string]string `json:"privacy"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// 个人数据导出服务，导出任务由后台协程逐个生成
+type ExportService struct {
+	db        *gorm.DB
+	friends   *FriendService
+	privacy   *PrivacyService
+	dir       string // 导出文件目录
+	avatarDir string
+	ttl       time.Duration
+	now       func() time.Time
+
+	queue chan int
+}
+
+func NewExportService(db *gorm.DB, friends *FriendService, privacy *PrivacyService, cfg ExportConfig, avatarDir string) *ExportService {
+	s := &ExportService{
+		db:        db,
+		friends:   friends,
+		privacy:   privacy,
+		dir:       cfg.Dir,
+		avatarDir: avatarDir,
+		ttl:       cfg.TTL,
+		now:       time.Now,
+		queue:     make(chan int, exportQueueSize),
+	}
+	return s
+}
+
+// 申请导出。已有未完成的导出时直接返回该记录
+func (s *ExportService) Request(userID int) (*DataExport, error) {
+	var export DataExport
+	err := s.db.Where("user_id = ? AND status = ?", userID, ExportPending).First(&export).Error
+	if err == nil {
+		return &export, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	export = DataExport{UserID: userID, Status: ExportPending}
+	if err := s.db.Create(&export).Error; err != nil {
+		return nil, err
+	}
+	if !s.enqueue(export.ID) {
+		s.db.Delete(&DataExport{}, export.ID)
+		return nil, ErrExportBusy
+	}
+	return &export, nil
+}
+
+func (s *ExportService) enqueue(id int) bool {
+	select {
+	case s.queue <- id:
+		return true
+	default:
+		return false
+	}
+}
+
+// 查询用户的导出记录
+func (s *ExportService) List(userID int) ([]DataExport, error) {
+	exports := []DataExport{}
+	err := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&exports).Error
+	return exports, err
+}
+
+// 获取可以下载的导出文件路径
+func (s *ExportService) File(userID, exportID int) (*DataExport, string, error) {
+	var export DataExport
+	if err := s.db.Where("id = ? AND user_id = ?", exportID, userID).First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrExportNotFound
+		}
+		return nil, "", err
+	}
+	switch {
+	case export.Status == ExportPending:
+		return nil, "", ErrExportNotReady
+	case export.ExpiresAt != nil && s.now().After(*export.ExpiresAt):
+		return nil, "", ErrExportExpired
+	case export.Status == ExportFailed:
+		return nil, "", ErrExportFailed
+	}
+	return &export, filepath.Join(s.dir, export.FileName), nil
+}
+
+// 后台生成导出文件。启动时先处理上次退出前未完成的任务；
+// ctx 结束后等正在生成的文件完成再退出，队列中剩下的任务在下次启动时继续
+func (s *ExportService) run(ctx context.Context) {
+	var pending []int
+	if err := s.db.Model(&DataExport{}).Where("status = ?", ExportPending).Order("id").Pluck("id", &pending).Error; err != nil {
+		log.Printf("查询未完成的导出任务失败: %v", err)
+	}
+	for _, id := range pending {
+		if ctx.Err() != nil {
+			return
+		}
+		s.process(id)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.queue:
+			s.process(id)
+		}
+	}
+}
+
+func (s *ExportService) process(id int) {
+	var export DataExport
+	if err := s.db.First(&export, id).Error; err != nil || export.Status != ExportPending {
+		return
+	}
+	fileName := fmt.Sprintf("export-%d-%d.zip", export.UserID, export.ID)
+	size, buildErr := s.build(export.UserID, filepath.Join(s.dir, fileName))
+
+	now := s.now()
+	updates := map[string]interface{}{"completed_at": now, "expires_at": now.Add(s.ttl)}
+	if buildErr != nil {
+		log.Printf("生成导出 %d 失败: %v", id, buildErr)
+		updates["status"] = ExportFailed
+		updates["error"] = truncate(buildErr.Error(), 255)
+	} else {
+		updates["status"] = ExportReady
+		updates["file_name"] = fileName
+		updates["size"] = size
+	}
+	if err := s.db.Model(&DataExport{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		log.Printf("更新导出 %d 状态失败: %v", id, err)
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// 生成包含个人数据的 ZIP 文件，先写入临时文件，完成后再重命名
+func (s *ExportService) build(userID int, path string) (int64, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".export-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := zip.NewWriter(tmp)
+	if err := s.writeArchive(zw, userID); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	return info.Size(), os.Rename(tmp.Name(), path)
+}
+
+func (s *ExportService) writeArchive(zw *zip.Writer, userID int) error {
+	var user User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	privacy, err := s.privacy.Settings(userID)
+	if err != nil {
+		return err
+	}
+	interests := []string{}
+	if err := s.db.Model(&UserInterest{}).Where("user_id = ?", userID).Order("position").Pluck("tag", &interests).Error; err != nil {
+		return err
+	}
+	profile := exportProfile{
+		ID:        user.ID,
+		UserName:  user.UserName,
+		NickName:  user.NickName,
+		Age:       user.Age,
+		Birthday:  user.Birthday,
+		Gender:    user.Gender,
+		Interests: interests,
+		Status:    user.Status,
+		Email:     user.Email,
+		Role:      normalizeRole(user.Role),
+		Privacy:   privacy,
+		CreatedAt: user.CreatedAt,
+	}
+
+	posts := []exportPost{}
+	comments := []Comment{}
+	postLikes := []Like{}
+	commentLikes := []CommentLike{}
+	sent := []Blessing{}
+	received := []Blessing{}
+	messages := []ChatMessage{}
+	for _, q := range []struct {
+		dest  interface{}
+		where string
+	}{
+		{&comments, "user_id = ?"},
+		{&postLikes, "user_id = ?"},
+		{&commentLikes, "user_id = ?"},
+		{&sent, "sender_id = ?"},
+		{&received, "receiver_id = ?"},
+	} {
+		if err := s.db.Where(q.where, userID).Order("id").Find(q.dest).Error; err != nil {
+			return err
+		}
+	}
+	if err := s.db.Where("sender_id = ? OR receiver_id = ?", userID, userID).Order("id").Find(&messages).Error; err != nil {
+		return err
+	}
+	var ownPosts []Post
+	if err := s.db.Unscoped().Where("user_id = ?", userID).Order("id").Find(&ownPosts).Error; err != nil {
+		return err
+	}
+	for _, p := range ownPosts {
+		post := exportPost{Post: p}
+		if p.DeletedAt.Valid {
+			post.DeletedAt = &p.DeletedAt.Time
+		}
+		posts = append(posts, post)
+	}
+	if err := s.exportAudiences(posts); err != nil {
+		return err
+	}
+	postEdits := []PostEdit{}
+	ownPostIDs := s.db.Unscoped().Model(&Post{}).Select("id").Where("user_id = ?", userID)
+	if err := s.db.Where("post_id IN (?)", ownPostIDs).Order("id").Find(&postEdits).Error; err != nil {
+		return err
+	}
+	friends, err := s.friends.List(userID)
+	if err != nil {
+		return err
+	}
+	if friends == nil {
+		friends = []FriendInfo{}
+	}
+
+	for _, f := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"posts.json", posts},
+		{"post_edits.json", postEdits},
+		{"comments.json", comments},
+		{"likes.json", gin.H{"posts": postLikes, "comments": commentLikes}},
+		{"blessings.json", gin.H{"sent": sent, "received": received}},
+		{"friends.json", friends},
+		{"messages.json", messages},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return err
+		}
+	}
+
+	var avatar Avatar
+	err = s.db.Where("user_id = ?", userID).First(&avatar).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(filepath.Join(s.avatarDir, avatar.Filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	w, err := zw.Create("avatar/" + avatar.Filename)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+// 删除过期的导出记录和文件，返回删除的数量
+func (s *ExportService) Cleanup() (int, error) {
+	var expired []DataExport
+	if err := s.db.Where("expires_at <= ?", s.now()).Find(&expired).Error; err != nil {
+		return 0, err
+	}
+	for _, e := range expired {
+		if err := s.remove(e); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+// 删除用户的全部导出记录和文件，用于注销清除
+func (s *ExportService) RemoveUser(userID int) error {
+	var exports []DataExport
+	if err := s.db.Where("user_id = ?", userID).Find(&exports).Error; err != nil {
+		return err
+	}
+	for _, e := range exports {
+		if err := s.remove(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *ExportService) remove(e DataExport) error {
+	if e.FileName != "" {
+		if err := os.Remove(filepath.Join(s.dir, e.FileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return s.db.Delete(&DataExport{}, e.ID).Error
+}
+
+// 后台定期清理过期的导出文件，ctx 结束后退出
+func (s *ExportService) runCleanup(ctx context.Context) {
+	ticker := time.NewTicker(exportCleanupInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Cleanup(); err != nil {
+			log.Printf("清理过期导出文件失败: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 申请导出个人数据
+func (a *App) requestExportHandler(c *gin.Context) {
+	export, err := a.exports.Request(c.MustGet("userID").(int))
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, export, "已开始导出，完成后可以下载")
+}
+
+// 查询导出记录
+func (a *App) listExportsHandler(c *gin.Context) {
+	exports, err := a.exports.List(c.MustGet("userID").(int))
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"exports": exports}, "查询成功")
+}
+
+// 下载导出文件
+func (a *App) downloadExportHandler(c *gin.Context) {
+	exportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ResponseError(c, ErrExportNotFound)
+		return
+	}
+	export, path, err := a.exports.File(c.MustGet("userID").(int), exportID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	c.FileAttachment(path, fmt.Sprintf("festival-blessing-export-%s.zip", export.CreatedAt.Format("20060102")))
+}
+
+// 填充可见范围为 custom 的说说的指定好友
+func (s *ExportService) exportAudiences(posts []exportPost) error {
+	var ids []int
+	for _, p := range posts {
+		if p.Visibility == VisibilityCustom {
+			ids = append(ids, p.ID)
+		}
+	}
+	audiences, err := NewLoader(s.db).PostAudiences(ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].AudienceIDs = audiences[posts[i].ID]
+	}
+	return nil
+}
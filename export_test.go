@@ -0,0 +1,167 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 申请导出并等待生成完成
+func (ts *testServer) exportData(token string) DataExport {
+	ts.t.Helper()
+	var export DataExport
+	mustDecode(ts.t, ts.do(http.MethodPost, "/account/export", token, nil), &export)
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var list struct {
+			Exports []DataExport `json:"exports"`
+		}
+		mustDecode(ts.t, ts.do(http.MethodGet, "/account/exports", token, nil), &list)
+		for _, e := range list.Exports {
+			if e.ID == export.ID && e.Status != ExportPending {
+				return e
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	ts.t.Fatalf("导出 %d 未在规定时间内完成", export.ID)
+	return export
+}
+
+// 下载导出文件，返回解压后的文件内容
+func (ts *testServer) downloadExport(token string, id int) map[string][]byte {
+	ts.t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, ts.server.URL+"/account/export/"+strconv.Itoa(id), nil)
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.Header.Get("Content-Type") != "application/zip" {
+		ts.t.Fatalf("下载失败: %s", data)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	return files
+}
+
+func TestDataExport(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	ts.befriend(alice, bob, bobID)
+	expectOK(t, ts.do(http.MethodPut, "/profile", alice, gin.H{"nickName": "小爱", "email": "alice@example.com"}))
+	ts.uploadAvatar(alice)
+	expectOK(t, ts.do(http.MethodPut, "/profile/privacy", alice, gin.H{"birthday": "friends"}))
+	postID := ts.createPost(alice, "新年快乐")
+	expectOK(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(postID)+"/like", alice, nil))
+	expectOK(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(postID)+"/comments", alice, gin.H{"content": "自己的评论"}))
+	// 已删除但尚未清除的说说及其修改记录同样导出
+	deletedID := ts.createPost(alice, "写错了")
+	expectOK(t, ts.do(http.MethodPut, "/posts/"+strconv.Itoa(deletedID), alice, gin.H{"content": "还是写错了"}))
+	expectOK(t, ts.do(http.MethodDelete, "/posts/"+strconv.Itoa(deletedID), alice, nil))
+	expectOK(t, ts.do(http.MethodPost, "/blessings", bob, gin.H{"receiver_id": aliceID, "content": "福", "font": "kaiti", "paper_style": "red"}))
+	ts.db.Create(&ChatMessage{SenderID: aliceID, ReceiverID: bobID, Content: "在吗", CreatedAt: time.Now()})
+
+	export := ts.exportData(alice)
+	if export.Status != ExportReady || export.Size == 0 || export.ExpiresAt == nil {
+		t.Fatalf("导出状态不符: %+v", export)
+	}
+	files := ts.downloadExport(alice, export.ID)
+	for _, name := range []string{"profile.json", "posts.json", "post_edits.json", "comments.json", "likes.json", "blessings.json", "friends.json", "messages.json", "avatar/" + strconv.Itoa(aliceID) + ".png"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("导出文件缺少 %s", name)
+		}
+	}
+
+	var profile exportProfile
+	json.Unmarshal(files["profile.json"], &profile)
+	if profile.UserName != "alice" || profile.NickName != "小爱" || profile.Email == nil || *profile.Email != "alice@example.com" ||
+		profile.Privacy["birthday"] != VisibilityFriends {
+		t.Errorf("个人信息不符: %+v", profile)
+	}
+	var posts []exportPost
+	json.Unmarshal(files["posts.json"], &posts)
+	var postEdits []PostEdit
+	json.Unmarshal(files["post_edits.json"], &postEdits)
+	if len(posts) != 2 || posts[0].DeletedAt != nil || posts[1].ID != deletedID || posts[1].DeletedAt == nil ||
+		len(postEdits) != 1 || postEdits[0].PostID != deletedID || postEdits[0].Content != "写错了" {
+		t.Errorf("说说或修改记录不符: posts=%+v edits=%+v", posts, postEdits)
+	}
+	var blessings struct {
+		Sent     []Blessing `json:"sent"`
+		Received []Blessing `json:"received"`
+	}
+	json.Unmarshal(files["blessings.json"], &blessings)
+	var friends []FriendInfo
+	json.Unmarshal(files["friends.json"], &friends)
+	var messages []ChatMessage
+	json.Unmarshal(files["messages.json"], &messages)
+	if len(blessings.Received) != 1 || len(blessings.Sent) != 0 || len(friends) != 1 || len(messages) != 1 {
+		t.Errorf("导出内容不符: blessings=%+v friends=%d messages=%d", blessings, len(friends), len(messages))
+	}
+
+	// 只能下载自己的导出
+	expectError(t, ts.do(http.MethodGet, "/account/export/"+strconv.Itoa(export.ID), bob, nil), "EXPORT_NOT_FOUND")
+}
+
+func TestDataExportExpiry(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	export := ts.exportData(alice)
+	var stored DataExport
+	ts.db.First(&stored, export.ID)
+	path := filepath.Join(ts.cfg.Export.Dir, stored.FileName)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("导出文件不存在: %v", err)
+	}
+
+	later := time.Now().Add(ts.cfg.Export.TTL + time.Minute)
+	ts.app.exports.now = func() time.Time { return later }
+	expectError(t, ts.do(http.MethodGet, "/account/export/"+strconv.Itoa(export.ID), alice, nil), "EXPORT_EXPIRED")
+
+	if n, err := ts.app.exports.Cleanup(); err != nil || n != 1 {
+		t.Fatalf("应清理 1 个过期导出: n=%d err=%v", n, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("过期文件应被删除: %v", err)
+	}
+	expectError(t, ts.do(http.MethodGet, "/account/export/"+strconv.Itoa(export.ID), alice, nil), "EXPORT_NOT_FOUND")
+}
+
+func TestDataExportPending(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+
+	// 未完成的导出不能下载，重复申请返回同一条记录
+	pending := DataExport{UserID: aliceID, Status: ExportPending}
+	ts.db.Create(&pending)
+	expectError(t, ts.do(http.MethodGet, "/account/export/"+strconv.Itoa(pending.ID), alice, nil), "EXPORT_NOT_READY")
+	var again DataExport
+	mustDecode(t, ts.do(http.MethodPost, "/account/export", alice, nil), &again)
+	if again.ID != pending.ID {
+		t.Fatalf("应返回未完成的导出 %d，实际 %d", pending.ID, again.ID)
+	}
+}
@@ -15,130 +15,249 @@ type FriendRequest struct {
 
 type FriendRelationship struct {
 	ID       int `gorm:"primary_key"`
-	UserID   int `gorm:"not null"`
-	FriendID int `gorm:"not null"`
+	UserID   int `gorm:"not null;uniqueIndex:idx_friend_relationships_user_friend"`
+	FriendID int `gorm:"not null;uniqueIndex:idx_friend_relationships_user_friend"`
 }
 
-func SendFriendRequest(c *gin.Context, db *gorm.DB) {
+// 好友信息
+type FriendInfo struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+// 待处理的好友请求
+type FriendRequestInfo struct {
+	RequestID int    `json:"request_id"`
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+}
+
+// 与其他用户的好友关系
+const (
+	RelationNone            = "none"
+	RelationFriend          = "friend"
+	RelationRequestSent     = "request_sent"     // 已向对方发送请求，等待对方接受
+	RelationRequestReceived = "request_received" // 对方发来的请求，等待自己接受
+)
+
+type Relation struct {
+	Status    string
+	RequestID int // 待处理的好友请求ID，仅在有请求时非零
+}
+
+var (
+	ErrFriendRequestExists   = newAppError("FRIEND_REQUEST_EXISTS", http.StatusConflict, "你已经发送过请求了", "Friend request already sent")
+	ErrFriendRequestNotFound = newAppError("FRIEND_REQUEST_NOT_FOUND", http.StatusNotFound, "没有找到请求", "Friend request not found")
+)
+
+// 好友领域服务
+type FriendService struct {
+	db   *gorm.DB
+	load *Loader
+}
+
+func NewFriendService(db *gorm.DB) *FriendService {
+	return &FriendService{db: db, load: NewLoader(db)}
+}
+
+func (s *FriendService) SendRequest(fromID, toID int) error {
+	var existingRequest FriendRequest
+	if err := s.db.Where("from_id = ? and to_id = ? and accepted_status = ?", fromID, toID, false).First(&existingRequest).Error; err == nil {
+		return ErrFriendRequestExists
+	}
+	//创建好友请求
+	return s.db.Create(&FriendRequest{FromID: fromID, ToID: toID}).Error
+}
+
+// 接受发给 userID 的好友请求
+func (s *FriendService) Accept(userID, requestID int) error {
+	//查找好友请求
+	var friendRequest FriendRequest
+	if err := s.db.First(&friendRequest, requestID).Error; err != nil || friendRequest.ToID != userID {
+		return ErrFriendRequestNotFound
+	}
+	//接受请求
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&friendRequest).Update("AcceptedStatus", true).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&FriendRelationship{UserID: friendRequest.FromID, FriendID: friendRequest.ToID}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&FriendRelationship{UserID: friendRequest.ToID, FriendID: friendRequest.FromID}).Error
+	})
+}
+
+func (s *FriendService) Delete(userID, friendID int) error {
+	return s.db.Where("(user_id = ? and friend_id = ?) OR (friend_id = ? and user_id = ?)", userID, friendID, userID, friendID).Delete(&FriendRelationship{}).Error
+}
+
+// 判断两人是否为好友
+func (s *FriendService) AreFriends(userID, otherID int) (bool, error) {
+	var count int64
+	err := s.db.Model(&FriendRelationship{}).
+		Where("(user_id = ? and friend_id = ?) or (user_id = ? and friend_id = ?)", userID, otherID, otherID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 批量查询 userID 与 otherIDs 中每个用户的关系
+func (s *FriendService) Relations(userID int, otherIDs []int) (map[int]Relation, error) {
+	relations := make(map[int]Relation, len(otherIDs))
+	if len(otherIDs) == 0 {
+		return relations, nil
+	}
+	for _, id := range otherIDs {
+		relations[id] = Relation{Status: RelationNone}
+	}
+
+	var requests []FriendRequest
+	err := s.db.Where("accepted_status = ? AND ((from_id = ? AND to_id IN ?) OR (to_id = ? AND from_id IN ?))", false, userID, otherIDs, userID, otherIDs).
+		Order("id").Find(&requests).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range requests {
+		if r.FromID == userID {
+			relations[r.ToID] = Relation{Status: RelationRequestSent, RequestID: r.ID}
+		} else {
+			relations[r.FromID] = Relation{Status: RelationRequestReceived, RequestID: r.ID}
+		}
+	}
+
+	var friendIDs []int
+	if err := s.db.Model(&FriendRelationship{}).Where("user_id = ? AND friend_id IN ?", userID, otherIDs).
+		Pluck("friend_id", &friendIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range friendIDs {
+		relations[id] = Relation{Status: RelationFriend}
+	}
+	return relations, nil
+}
+
+// 查询好友
+func (s *FriendService) List(userID int) ([]FriendInfo, error) {
+	var friends []FriendRelationship
+	if err := s.db.Where("user_id = ?", userID).Order("friend_id").Find(&friends).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(friends))
+	for i, f := range friends {
+		ids[i] = f.FriendID
+	}
+	users, err := s.load.Users(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var friendList []FriendInfo
+	for _, id := range ids {
+		if friend, ok := users[id]; ok { // 跳过已注销的用户
+			friendList = append(friendList, FriendInfo{ID: friend.ID, Username: friend.UserName})
+		}
+	}
+	return friendList, nil
+}
+
+// 查询当前用户收到的所有好友请求，且未接受的
+func (s *FriendService) PendingRequests(userID int) ([]FriendRequestInfo, error) {
+	var requests []FriendRequest
+	if err := s.db.Where("to_id = ? AND accepted_status = ?", userID, false).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]int, len(requests))
+	for i, req := range requests {
+		ids[i] = req.FromID
+	}
+	users, err := s.load.Users(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// 组合好友请求信息
+	var requestList []FriendRequestInfo
+	for _, req := range requests {
+		user, ok := users[req.FromID]
+		if !ok {
+			continue // 如果找不到用户，则跳过
+		}
+		requestList = append(requestList, FriendRequestInfo{
+			RequestID: req.ID,
+			UserID:    user.ID,
+			Username:  user.UserName,
+		})
+	}
+	return requestList, nil
+}
+
+func (a *App) SendFriendRequest(c *gin.Context) {
 	fromID := c.GetInt("userID")
 	var request struct {
 		ToID int `json:"to_id"`
 	}
 	if err := c.ShouldBind(&request); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "Invalid input")
+		ResponseError(c, invalidRequest(err))
 		return
 	}
-	var existingRequest FriendRequest
-	if err := db.Where("from_id = ? and to_id = ? and accepted_status = 0", fromID, request.ToID).First(&existingRequest).Error; err == nil {
-		ResponseFAIL(c, http.StatusBadRequest, "你已经发送过请求了")
+	if err := a.friends.SendRequest(fromID, request.ToID); err != nil {
+		ResponseError(c, err)
 		return
 	}
-	//创建好友请求
-	friendRequest := FriendRequest{FromID: fromID, ToID: request.ToID}
-	db.Create(&friendRequest)
 	ResponseOK(c, nil, "已经发送添加请求")
 }
 
-func AcceptFriendRequest(c *gin.Context) {
+func (a *App) AcceptFriendRequest(c *gin.Context) {
 	userID := c.GetInt("userID")
 	var request struct {
 		RequestID int `json:"request_id"`
 	}
 	if err := c.ShouldBind(&request); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "Invalid input")
+		ResponseError(c, invalidRequest(err))
 		return
 	}
-	//查找好友请求
-	var friendRequest FriendRequest
-	if err := db.First(&friendRequest, request.RequestID).Error; err != nil || friendRequest.ToID != userID {
-		ResponseFAIL(c, http.StatusNotFound, "没有找到请求")
+	if err := a.friends.Accept(userID, request.RequestID); err != nil {
+		ResponseError(c, err)
 		return
 	}
-	//接受请求
-	db.Model(&friendRequest).Update("AcceptedStatus", true)
-	db.Create(&FriendRelationship{UserID: friendRequest.FromID, FriendID: friendRequest.ToID})
-	db.Create(&FriendRelationship{UserID: friendRequest.ToID, FriendID: friendRequest.FromID})
-
 	ResponseOK(c, nil, "已接受好友请求")
 }
 
-func DeleteFriendRequest(c *gin.Context) {
+func (a *App) DeleteFriendRequest(c *gin.Context) {
 	userID := c.GetInt("userID")
 	var request struct {
 		FriendID int `json:"friend_id"`
 	}
 	if err := c.ShouldBind(&request); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, "Invalid input")
+		ResponseError(c, invalidRequest(err))
 		return
 	}
 
-	db.Where("(user_id = ? and friend_id = ?) OR (friend_id = ? and user_id = ?)", userID, request.FriendID, userID, request.FriendID).Delete(&FriendRelationship{})
+	if err := a.friends.Delete(userID, request.FriendID); err != nil {
+		ResponseError(c, err)
+		return
+	}
 	ResponseOK(c, nil, "好友已经删除")
 }
 
 // 查询好友
-func GetAllFriends(c *gin.Context) {
+func (a *App) GetAllFriends(c *gin.Context) {
 	userID := c.GetInt("userID")
-	var friends []FriendRelationship
-	err := db.Where("user_id = ?", userID).Find(&friends).Error
+	friendList, err := a.friends.List(userID)
 	if err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "获取好友失败")
+		ResponseError(c, err)
 		return
 	}
-
-	var friendList []struct {
-		ID       int    `json:"id"`
-		Username string `json:"username"`
-	}
-
-	for _, f := range friends {
-		var friend User
-		if f.UserID != userID {
-			if err := db.First(&friend, f.UserID).Error; err == nil {
-				friendList = append(friendList, struct {
-					ID       int    `json:"id"`
-					Username string `json:"username"`
-				}{
-					ID:       friend.ID,
-					Username: friend.UserName,
-				})
-			}
-		} else {
-			if err := db.First(&friend, f.FriendID).Error; err == nil {
-				friendList = append(friendList, struct {
-					ID       int    `json:"id"`
-					Username string `json:"username"`
-				}{
-					ID:       friend.ID,
-					Username: friend.UserName,
-				})
-			}
-		}
-	}
 	ResponseOK(c, gin.H{"friends": friendList}, "获取好友列表成功")
 }
 
-func GetAllReceivedFriendRequests(c *gin.Context) {
+func (a *App) GetAllReceivedFriendRequests(c *gin.Context) {
 	userID := c.GetInt("userID")
-	// 查询当前用户收到的所有好友请求，且未接受的
-	var requests []FriendRequest
-	err := db.Where("to_id = ? AND accepted_status = ?", userID, false).Find(&requests).Error
+	requestList, err := a.friends.PendingRequests(userID)
 	if err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "获取好友请求失败")
+		ResponseError(c, err)
 		return
 	}
-	// 组合好友请求信息
-	var requestList []gin.H
-	for _, req := range requests {
-		var user User
-		if err := db.First(&user, req.FromID).Error; err != nil {
-			continue // 如果找不到用户，则跳过
-		}
-		requestList = append(requestList, gin.H{
-			"request_id": req.ID,
-			"user_id":    user.ID,
-			"username":   user.UserName,
-		})
-	}
 	ResponseOK(c, gin.H{"requests": requestList}, "成功获取所有未接受的好友请求")
 }
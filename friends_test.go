@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type friendList struct {
+	Friends []struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	} `json:"friends"`
+}
+
+func TestFriendLifecycle(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+
+	expectOK(t, ts.do(http.MethodPost, "/friend/request", alice, gin.H{"to_id": bobID}))
+	expectCode(t, ts.do(http.MethodPost, "/friend/request", alice, gin.H{"to_id": bobID}), http.StatusConflict)
+	expectError(t, ts.do(http.MethodPost, "/friend/request", alice, gin.H{"to_id": bobID}), "FRIEND_REQUEST_EXISTS")
+
+	var requests struct {
+		Requests []struct {
+			RequestID int    `json:"request_id"`
+			UserID    int    `json:"user_id"`
+			Username  string `json:"username"`
+		} `json:"requests"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/friend/getrequests", bob, nil), &requests)
+	if len(requests.Requests) != 1 || requests.Requests[0].UserID != aliceID {
+		t.Fatalf("收到的好友请求不符: %+v", requests)
+	}
+
+	// 只有接收方可以接受请求
+	expectCode(t, ts.do(http.MethodPost, "/friend/accept", alice, gin.H{"request_id": requests.Requests[0].RequestID}), http.StatusNotFound)
+	expectOK(t, ts.do(http.MethodPost, "/friend/accept", bob, gin.H{"request_id": requests.Requests[0].RequestID}))
+
+	var friends friendList
+	mustDecode(t, ts.do(http.MethodGet, "/friend/list", alice, nil), &friends)
+	if len(friends.Friends) != 1 || friends.Friends[0].ID != bobID || friends.Friends[0].Username != "bob" {
+		t.Fatalf("好友列表不符: %+v", friends)
+	}
+
+	requests.Requests = nil
+	mustDecode(t, ts.do(http.MethodGet, "/friend/getrequests", bob, nil), &requests)
+	if len(requests.Requests) != 0 {
+		t.Fatalf("已接受的请求不应再出现: %+v", requests)
+	}
+
+	expectOK(t, ts.do(http.MethodPost, "/friend/delete", bob, gin.H{"friend_id": aliceID}))
+	friends = friendList{}
+	mustDecode(t, ts.do(http.MethodGet, "/friend/list", alice, nil), &friends)
+	if len(friends.Friends) != 0 {
+		t.Fatalf("删除后好友列表应为空: %+v", friends)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 构建信息，构建时通过 -ldflags "-X main.buildCommit=... -X main.buildTime=..." 注入
+var (
+	buildCommit = ""
+	buildTime   = ""
+)
+
+const readyCheckTimeout = 2 * time.Second
+
+// 依赖检查结果。接口无需认证，响应中不包含错误详情，详情只写入日志
+const (
+	checkOK   = "ok"
+	checkDown = "down"
+)
+
+// 构建版本信息
+type BuildInfo struct {
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// 读取构建信息，未注入时回退到 Go 工具链记录的 VCS 信息
+func currentBuildInfo() BuildInfo {
+	info := BuildInfo{Commit: buildCommit, BuildTime: buildTime, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
+
+// 进程存活检查
+func (a *App) healthzHandler(c *gin.Context) {
+	ResponseOK(c, gin.H{"status": "ok"}, "ok")
+}
+
+// 就绪检查：数据库、Redis 可用且没有待执行的迁移，否则返回 503
+func (a *App) readyzHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyCheckTimeout)
+	defer cancel()
+
+	ready := true
+	check := func(name string, err error) string {
+		if err != nil {
+			ready = false
+			log.Printf("就绪检查失败: %s: %v", name, err)
+			return checkDown
+		}
+		return checkOK
+	}
+
+	var dbErr error
+	if sqlDB, err := a.db.DB(); err != nil {
+		dbErr = err
+	} else {
+		dbErr = sqlDB.PingContext(ctx)
+	}
+	checks := gin.H{
+		"db":    check("db", dbErr),
+		"redis": check("redis", a.rdb.Ping(ctx).Err()),
+	}
+
+	migrationState := gin.H{"status": "unknown"}
+	if dbErr == nil {
+		pending, err := NewMigrator(a.db.WithContext(ctx)).Pending()
+		switch {
+		case err != nil:
+			ready = false
+			log.Printf("就绪检查失败: migrations: %v", err)
+			migrationState = gin.H{"status": "fail"}
+		case pending > 0:
+			ready = false
+			migrationState = gin.H{"status": "pending", "pending": pending}
+		default:
+			migrationState = gin.H{"status": "ok", "pending": 0}
+		}
+	}
+	checks["migrations"] = migrationState
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"code": http.StatusServiceUnavailable,
+			"data": checks,
+			"msg":  "服务未就绪",
+		})
+		return
+	}
+	ResponseOK(c, checks, "ok")
+}
+
+// 构建版本信息
+func (a *App) versionHandler(c *gin.Context) {
+	ResponseOK(c, currentBuildInfo(), "ok")
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestHealthz(t *testing.T) {
+	ts := newTestServer(t)
+	expectOK(t, ts.do(http.MethodGet, "/healthz", "", nil))
+
+	var info BuildInfo
+	mustDecode(t, ts.do(http.MethodGet, "/version", "", nil), &info)
+	if info.GoVersion != runtime.Version() || info.Commit == "" || info.BuildTime == "" {
+		t.Fatalf("构建信息不完整: %+v", info)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	ts := newTestServer(t)
+
+	resp := ts.do(http.MethodGet, "/readyz", "", nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("依赖正常时应就绪，实际 %d: %s", resp.Status, resp.Data)
+	}
+
+	// 存在未执行的迁移时不就绪
+	if _, err := NewMigrator(ts.db).Down(); err != nil {
+		t.Fatal(err)
+	}
+	resp = ts.do(http.MethodGet, "/readyz", "", nil)
+	var checks struct {
+		Migrations struct {
+			Status  string `json:"status"`
+			Pending int    `json:"pending"`
+		} `json:"migrations"`
+	}
+	json.Unmarshal(resp.Data, &checks)
+	if resp.Status != http.StatusServiceUnavailable || checks.Migrations.Status != "pending" || checks.Migrations.Pending != 1 {
+		t.Fatalf("存在待执行迁移时应返回 503: %d %s", resp.Status, resp.Data)
+	}
+	if _, err := NewMigrator(ts.db).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Redis 不可用时不就绪
+	ts.redis.Close()
+	resp = ts.do(http.MethodGet, "/readyz", "", nil)
+	var deps map[string]interface{}
+	json.Unmarshal(resp.Data, &deps)
+	if resp.Status != http.StatusServiceUnavailable || deps["redis"] != "down" || deps["db"] != "ok" {
+		t.Fatalf("Redis 不可用时应返回 503: %d %s", resp.Status, resp.Data)
+	}
+}
@@ -15,44 +15,72 @@ type Avatar struct {
 	URL      string
 }
 
-func UploadAvatar(c *gin.Context, db *gorm.DB) {
+var ErrAvatarFileMissing = newAppError("AVATAR_FILE_MISSING", http.StatusBadRequest, "缺少头像文件", "Missing avatar image file")
+
+// 头像领域服务
+type AvatarService struct {
+	db  *gorm.DB
+	dir string // 头像文件存储目录
+}
+
+func NewAvatarService(db *gorm.DB, dir string) *AvatarService {
+	return &AvatarService{db: db, dir: dir}
+}
+
+// 根据用户ID和原始文件名生成唯一文件名，返回文件名和存储路径
+func (s *AvatarService) FilePath(userID int, originalName string) (string, string) {
+	ext := filepath.Ext(originalName)
+	filename := fmt.Sprintf("%d%s", userID, ext)
+	return filename, filepath.Join(s.dir, filename)
+}
+
+// 记录用户的头像文件
+func (s *AvatarService) Save(userID int, filename string) (*Avatar, error) {
+	var image Avatar
+	if err := s.db.Where("user_id = ?", userID).First(&image).Error; err != nil {
+		// 如果没有找到记录, 创建一个新的记录
+		image = Avatar{
+			UserId:   userID,
+			Filename: filename,
+			URL:      "/avatar/" + filename,
+		}
+		return &image, s.db.Create(&image).Error
+	}
+	image.Filename = filename
+	image.URL = "/avatar/" + filename
+	return &image, s.db.Save(&image).Error
+}
+
+// 用户头像的访问地址，没有头像时为空
+func (s *AvatarService) URL(userID int) (string, error) {
+	var urls []string
+	err := s.db.Model(&Avatar{}).Where("user_id = ?", userID).Order("id DESC").Limit(1).Pluck("url", &urls).Error
+	if err != nil || len(urls) == 0 {
+		return "", err
+	}
+	return urls[0], nil
+}
+
+func (a *App) UploadAvatar(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
-	uploadDir := "avatar"
 	file, err := c.FormFile("image")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ResponseError(c, ErrAvatarFileMissing.Wrap(err))
 		return
 	}
 
-	// 生成唯一文件名
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%d%s", userID, ext)
-
 	// 保存文件
-	dst := filepath.Join(uploadDir, filename)
+	filename, dst := a.avatars.FilePath(userID, file.Filename)
 	if err := c.SaveUploadedFile(file, dst); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ResponseError(c, err)
 		return
 	}
 
 	// 保存到数据库
-	var image Avatar
-
-	if err := db.Where("user_id = ?", userID).First(&image).Error; err != nil {
-		// 如果没有找到记录, 创建一个新的记录
-		image = Avatar{
-			UserId:   userID,
-			Filename: filename,
-			URL:      "/avatar/" + filename,
-		}
-		db.Create(&image)
-	} else {
-		image = Avatar{
-			UserId:   userID,
-			Filename: filename,
-			URL:      "/avatar/" + filename,
-		}
-		db.Save(&image)
+	image, err := a.avatars.Save(userID, filename)
+	if err != nil {
+		ResponseError(c, err)
+		return
 	}
 
 	ResponseOK(c, gin.H{
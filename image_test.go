@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestUploadAvatar(t *testing.T) {
+	ts := newTestServer(t)
+	userID, token := ts.signup("alice")
+
+	content := []byte("\x89PNG\r\n\x1a\nfake")
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("image", "me.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, ts.server.URL+"/avatar/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var uploaded struct {
+		URL string `json:"url"`
+	}
+	mustDecode(t, ts.send(req, token), &uploaded)
+
+	want := "/avatar/" + strconv.Itoa(userID) + ".png"
+	if uploaded.URL != want {
+		t.Fatalf("头像地址不符: %q, 期望 %q", uploaded.URL, want)
+	}
+	if saved, err := os.ReadFile(filepath.Join(ts.cfg.Upload.Dir, strconv.Itoa(userID)+".png")); err != nil || !bytes.Equal(saved, content) {
+		t.Fatalf("头像文件未正确保存: %v", err)
+	}
+
+	resp, err := http.Get(ts.server.URL + uploaded.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	served, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(served, content) {
+		t.Fatalf("静态头像访问失败: status=%d", resp.StatusCode)
+	}
+
+	// 缺少文件字段
+	req, _ = http.NewRequest(http.MethodPost, ts.server.URL+"/avatar/upload", nil)
+	expectError(t, ts.send(req, token), "AVATAR_FILE_MISSING")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"gorm.io/gorm"
+)
+
+// 批量加载器：列表中每一行需要的关联数据按一组 ID 一次查出，
+// 查询次数不随列表长度增加
+type Loader struct {
+	db *gorm.DB
+}
+
+func NewLoader(db *gorm.DB) *Loader {
+	return &Loader{db: db}
+}
+
+// 查询 model 中 column 属于 ids 且满足附加条件的记录，返回出现过的 column 值集合
+func (l *Loader) idSet(model interface{}, column string, ids []int, query string, args ...interface{}) (map[int]bool, error) {
+	set := make(map[int]bool, len(ids))
+	if len(ids) == 0 {
+		return set, nil
+	}
+	var found []int
+	err := l.db.Model(model).Where(column+" IN ?", ids).Where(query, args...).Pluck(column, &found).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// 用户点赞过的说说
+func (l *Loader) LikedPosts(userID int, postIDs []int) (map[int]bool, error) {
+	return l.idSet(&Like{}, "post_id", postIDs, "user_id = ?", userID)
+}
+
+// 用户点赞过的评论
+func (l *Loader) LikedComments(userID int, commentIDs []int) (map[int]bool, error) {
+	return l.idSet(&CommentLike{}, "comment_id", commentIDs, "user_id = ?", userID)
+}
+
+// 可见范围为 custom 的说说指定的用户，按用户 ID 升序
+func (l *Loader) PostAudiences(postIDs []int) (map[int][]int, error) {
+	audiences := make(map[int][]int, len(postIDs))
+	if len(postIDs) == 0 {
+		return audiences, nil
+	}
+	var rows []PostAudience
+	if err := l.db.Where("post_id IN ?", postIDs).Order("post_id, user_id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		audiences[r.PostID] = append(audiences[r.PostID], r.UserID)
+	}
+	return audiences, nil
+}
+
+// 按 ID 加载用户，已注销的用户不在结果中
+func (l *Loader) Users(ids []int) (map[int]*User, error) {
+	users := make(map[int]*User, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+	var found []User
+	if err := l.db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	for i := range found {
+		users[found[i].ID] = &found[i]
+	}
+	return users, nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// 统计数据库上执行的查询次数
+func countQueries(t testing.TB, db *gorm.DB) *int64 {
+	t.Helper()
+	var n int64
+	count := func(*gorm.DB) { atomic.AddInt64(&n, 1) }
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Query().After("gorm:query").Register("test:count_query", count),
+		cb.Row().After("gorm:row").Register("test:count_row", count),
+		cb.Raw().After("gorm:raw").Register("test:count_raw", count),
+	} {
+		if err != nil {
+			t.Fatalf("注册回调失败: %v", err)
+		}
+	}
+	return &n
+}
+
+// 已迁移的数据库，其中 viewer 点赞并评论了 n 条说说，并与 n 个用户互为好友、收到 n 个好友请求
+type loaderFixture struct {
+	db      *gorm.DB
+	viewer  int
+	postID  int // 带有 n 条评论的说说
+	queries *int64
+}
+
+func newLoaderFixture(t testing.TB, n int) loaderFixture {
+	t.Helper()
+	db := newTestDB(t)
+	if _, err := NewMigrator(db).Up(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	mustCreate := func(v interface{}) {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatalf("创建数据失败: %v", err)
+		}
+	}
+
+	viewer := User{UserName: "viewer", Password: "x"}
+	mustCreate(&viewer)
+	for i := 0; i < n; i++ {
+		friend := User{UserName: "friend" + strconv.Itoa(i), Password: "x"}
+		mustCreate(&friend)
+		mustCreate(&FriendRelationship{UserID: viewer.ID, FriendID: friend.ID})
+		mustCreate(&FriendRelationship{UserID: friend.ID, FriendID: viewer.ID})
+
+		stranger := User{UserName: "stranger" + strconv.Itoa(i), Password: "x"}
+		mustCreate(&stranger)
+		mustCreate(&FriendRequest{FromID: stranger.ID, ToID: viewer.ID})
+
+		post := Post{UserID: friend.ID, Content: "说说"}
+		mustCreate(&post)
+		mustCreate(&Like{PostID: post.ID, UserID: viewer.ID})
+	}
+
+	post := Post{UserID: viewer.ID, Content: "新年快乐"}
+	mustCreate(&post)
+	for i := 0; i < n; i++ {
+		comment := Comment{PostID: post.ID, UserID: viewer.ID, Content: "评论"}
+		mustCreate(&comment)
+		if i%2 == 0 {
+			mustCreate(&CommentLike{CommentID: comment.ID, UserID: viewer.ID})
+		}
+	}
+	return loaderFixture{db: db, viewer: viewer.ID, postID: post.ID, queries: countQueries(t, db)}
+}
+
+// 执行 fn 并返回期间的查询次数
+func (f loaderFixture) count(t testing.TB, fn func() error) int64 {
+	t.Helper()
+	before := atomic.LoadInt64(f.queries)
+	if err := fn(); err != nil {
+		t.Fatal(err)
+	}
+	return atomic.LoadInt64(f.queries) - before
+}
+
+// 各个列表的查询次数，同时检查批量加载的结果
+func (f loaderFixture) listQueries(t *testing.T, n int) map[string]int64 {
+	t.Helper()
+	posts, comments, friends := NewPostService(f.db), NewCommentService(f.db), NewFriendService(f.db)
+	p := page{limit: maxPageLimit}
+	counts := make(map[string]int64)
+
+	counts["posts"] = f.count(t, func() error {
+		list, _, err := posts.Feed(f.viewer, p)
+		for _, post := range list {
+			if post.IsLiked != (post.UserID != f.viewer) {
+				t.Errorf("说说 %d 的点赞状态不符", post.ID)
+			}
+		}
+		return err
+	})
+	counts["comments"] = f.count(t, func() error {
+		list, _, err := comments.List(f.viewer, f.postID, p)
+		if len(list) != n {
+			t.Errorf("评论数量不符: %d", len(list))
+		}
+		liked := 0
+		for _, c := range list {
+			if c.IsLiked {
+				liked++
+			}
+		}
+		if liked != (n+1)/2 {
+			t.Errorf("点赞的评论数量不符: %d", liked)
+		}
+		return err
+	})
+	counts["friends"] = f.count(t, func() error {
+		list, err := friends.List(f.viewer)
+		if len(list) != n {
+			t.Errorf("好友数量不符: %d", len(list))
+		}
+		for i, friend := range list {
+			if friend.Username != "friend"+strconv.Itoa(i) {
+				t.Errorf("好友顺序不符: %+v", list)
+				break
+			}
+		}
+		return err
+	})
+	counts["requests"] = f.count(t, func() error {
+		list, err := friends.PendingRequests(f.viewer)
+		if len(list) != n {
+			t.Errorf("好友请求数量不符: %d", len(list))
+		}
+		return err
+	})
+	return counts
+}
+
+func TestLoaderQueryCountConstant(t *testing.T) {
+	small := newLoaderFixture(t, 2).listQueries(t, 2)
+	large := newLoaderFixture(t, 30).listQueries(t, 30)
+	for name, n := range small {
+		if large[name] != n {
+			t.Errorf("%s: 查询次数随列表长度增加: %d 条时 %d 次，30 条时 %d 次", name, 2, n, large[name])
+		}
+	}
+}
+
+func TestLoaderSkipsDeletedUsers(t *testing.T) {
+	f := newLoaderFixture(t, 3)
+	f.db.Delete(&User{}, "user_name = ?", "friend1")
+	list, err := NewFriendService(f.db).List(f.viewer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Username != "friend0" || list[1].Username != "friend2" {
+		t.Fatalf("应跳过已注销的好友: %+v", list)
+	}
+	users, err := NewLoader(f.db).Users(nil)
+	if err != nil || len(users) != 0 {
+		t.Fatalf("空 ID 列表不应查询: %v %v", users, err)
+	}
+}
+
+func BenchmarkListPosts(b *testing.B) {
+	for _, n := range []int{10, 50, 100} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			f := newLoaderFixture(b, n)
+			posts := NewPostService(f.db)
+			p := page{limit: n}
+			before := atomic.LoadInt64(f.queries)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := posts.Feed(f.viewer, p); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(f.queries)-before)/float64(b.N), "queries/op")
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 一封待发送的邮件
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// 邮件发送器，本地开发使用 log 或 file 实现，生产环境使用 SMTP
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// 根据配置创建邮件发送器
+func NewMailer(cfg MailConfig) Mailer {
+	switch cfg.Driver {
+	case MailDriverFile:
+		return &FileMailer{dir: cfg.Dir, from: cfg.From}
+	case MailDriverSMTP:
+		return &SMTPMailer{cfg: cfg.SMTP, from: cfg.From}
+	default:
+		return LogMailer{}
+	}
+}
+
+// 只把收件人和主题写入日志，不真正发送，仅用于开发。
+// 正文中可能包含重置密码令牌等凭据，不写入日志；需要查看正文时使用 file 驱动
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, mail Mail) error {
+	log.Printf("邮件 to=%s subject=%q（正文已省略，%d 字节）", mail.To, mail.Subject, len(mail.Body))
+	return nil
+}
+
+// 把每封邮件写成目录中的一个 .eml 文件，便于本地查看
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func (m *FileMailer) Send(ctx context.Context, mail Mail) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+	suffix, err := randomToken(4)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102T150405"), suffix)
+	return os.WriteFile(filepath.Join(m.dir, name), buildMessage(m.from, mail), 0o600)
+}
+
+// 通过 SMTP 服务器发送邮件，服务器支持 STARTTLS 时自动加密（不支持 465 端口的隐式 TLS）
+type SMTPMailer struct {
+	cfg  SMTPConfig
+	from string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, mail Mail) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+	// net/smtp 不支持 context，放到协程中以便请求取消时及时返回
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, m.from, []string{mail.To}, buildMessage(m.from, mail))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 构造 RFC 5322 格式的纯文本邮件
+func buildMessage(from string, mail Mail) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer := NewMailer(MailConfig{Driver: MailDriverFile, From: "no-reply@example.com", Dir: dir})
+
+	if err := mailer.Send(context.Background(), Mail{To: "alice@example.com", Subject: "重置密码", Body: "第一行\n第二行"}); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("期望写入 1 封邮件，实际 %v err=%v", files, err)
+	}
+	data, _ := os.ReadFile(files[0])
+	for _, want := range []string{"From: no-reply@example.com\r\n", "To: alice@example.com\r\n", "Subject: =?utf-8?q?", "\r\n\r\n第一行\r\n第二行"} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("邮件缺少 %q:\n%s", want, data)
+		}
+	}
+}
+
+// 日志中不应出现邮件正文，正文中包含重置令牌
+func TestLogMailerOmitsBody(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	if err := (LogMailer{}).Send(context.Background(), Mail{To: "alice@example.com", Subject: "重置密码", Body: "secret-reset-token"}); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "alice@example.com") || strings.Contains(out, "secret-reset-token") {
+		t.Fatalf("日志内容不符: %s", out)
+	}
+}
@@ -1,57 +1,243 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"time"
 )
 
 func main() {
-	db := InitDb()
+	configPath := flag.String("config", "config.yaml", "配置文件路径")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		conn, err := OpenDatabase(cfg.Database)
+		if err != nil {
+			log.Fatalf("连接数据库失败: %v", err)
+		}
+		switch args[0] {
+		case "migrate":
+			err = runMigrate(NewMigrator(conn), args[1:], os.Stdout)
+		case "set-role":
+			err = runSetRole(conn, args[1:], os.Stdout)
+		case "purge-accounts":
+			var app *App
+			if app, err = NewApp(cfg, conn, InitRedis(cfg.Redis)); err == nil {
+				err = runPurgeAccounts(context.Background(), app, os.Stdout)
+				app.Close(context.Background())
+			}
+		default:
+			log.Fatalf("未知的命令 %q，可用: migrate、set-role、purge-accounts", args[0])
+		}
+		if err != nil {
+			log.Fatalf("%s 失败: %v", args[0], err)
+		}
+		return
+	}
+
+	db, err := InitDb(cfg.Database)
+	if err != nil {
+		log.Fatalf("初始化数据库失败: %v", err)
+	}
+	rdb := InitRedis(cfg.Redis)
+	if err := os.MkdirAll(cfg.Upload.Dir, 0o755); err != nil {
+		log.Fatalf("创建上传目录失败: %v", err)
+	}
+
+	app, err := NewApp(cfg, db, rdb)
+	if err != nil {
+		log.Fatalf("初始化应用失败: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := app.Serve(ctx); err != nil {
+		log.Fatalf("服务异常退出: %v", err)
+	}
+}
+
+// 启动 HTTP 服务和后台任务，ctx 结束后在 ShutdownTimeout 内优雅关闭：
+// 停止接收新连接、等待进行中的请求和后台任务、关闭聊天连接，最后释放数据库等资源
+func (a *App) Serve(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:    a.cfg.Server.Addr,
+		Handler: a.Router(),
+	}
+
+	// 后台任务随 ctx 结束退出，释放资源前等待它们完成
+	ctx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
+	var background sync.WaitGroup
+	for _, run := range []func(context.Context){a.runAccountPurger, a.exports.runCleanup, a.exports.run} {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(ctx)
+		}()
+	}
 
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("服务监听于 %s", a.cfg.Server.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		stopBackground()
+		background.Wait()
+		a.Close(context.Background())
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("收到退出信号，开始优雅关闭（最长 %s）", a.cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("关闭 HTTP 服务: %w", err))
+	}
+	if err := waitGroup(shutdownCtx, &background); err != nil {
+		errs = append(errs, fmt.Errorf("等待后台任务: %w", err))
+	}
+	if err := a.Close(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Println("服务已退出")
+	return nil
+}
+
+// 等待 wg 中的协程全部退出，ctx 到期时提前返回
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 构建路由，main 和测试共用
+func (a *App) Router() *gin.Engine {
 	r := gin.Default()
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},                                       // 允许所有域名访问
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}, // 允许的方法
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"}, // 允许的头部
+		AllowOrigins:     a.cfg.CORS.AllowOrigins,                                      // 允许访问的域名
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}, // 允许的方法
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},          // 允许的头部
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,           // 是否允许携带认证信息
 		MaxAge:           12 * time.Hour, // 缓存预检请求的时间
 	}))
-	r.Static("/avatar", "./avatar")
+	r.Use(errorModeMiddleware(a.cfg.Server.RealStatusCodes))
+	r.Static("/avatar", a.cfg.Upload.Dir)
+
+	// 健康检查与构建信息
+	r.GET("/healthz", a.healthzHandler)
+	r.GET("/readyz", a.readyzHandler)
+	r.GET("/version", a.versionHandler)
 
 	// 公共路由
-	r.POST("/register", func(c *gin.Context) { registerHandler(c, db) })
-	r.POST("/login", func(c *gin.Context) { loginHandler(c, db) })
-	r.GET("/profile", func(c *gin.Context) { getProfileHandler(c, db) })
+	r.POST("/register", a.rateLimit("register", a.cfg.RateLimit.Register), a.registerHandler)
+	r.GET("/register/check", a.rateLimit("register_check", a.cfg.RateLimit.RegisterCheck), a.checkUserNameHandler)
+	r.POST("/login", a.rateLimit("login", a.cfg.RateLimit.Login), a.loginHandler)
+	r.GET("/profile", a.optionalAuthMiddleware, a.getProfileHandler)
+	r.POST("/login/2fa", a.rateLimit("login", a.cfg.RateLimit.Login), a.loginTwoFactorHandler)
+	r.POST("/auth/refresh", a.refreshHandler)
+	r.POST("/password/forgot", a.rateLimit("password_forgot", a.cfg.RateLimit.PasswordForgot), a.forgotPasswordHandler)
+	r.POST("/password/reset", a.resetPasswordHandler)
+	r.POST("/account/restore", a.rateLimit("login", a.cfg.RateLimit.Login), a.restoreAccountHandler)
 
 	// 需要认证的路由
 	authGroup := r.Group("/")
-	authGroup.Use(func(c *gin.Context) { authMiddleware(c, db) }) // 传入 db
+	authGroup.Use(a.authMiddleware)
 	{
-		authGroup.PUT("/profile", func(c *gin.Context) { updateProfileHandler(c, db) })
-		authGroup.DELETE("/account", func(c *gin.Context) { deleteAccountHandler(c, db) })
-		authGroup.POST("/posts", func(c *gin.Context) { createPostHandler(c, db) })
-		authGroup.GET("/posts", func(c *gin.Context) { getPostsHandler(c, db) })
-		authGroup.POST("/posts/:id/like", func(c *gin.Context) { likePostHandler(c, db) })
-		authGroup.POST("/posts/:id/unlike", func(c *gin.Context) { unlikePostHandler(c, db) })
-		authGroup.GET("/posts/liked", func(c *gin.Context) { getLikedPostsHandler(c, db) }) // 查询某人已点赞的帖子
-		authGroup.POST("/posts/:id/comments", func(c *gin.Context) { createCommentHandler(c, db) })
-		authGroup.GET("/posts/:id/comments", func(c *gin.Context) { getCommentsHandler(c, db) })
-		authGroup.POST("/comments/:id/like", func(c *gin.Context) { likeCommentHandler(c, db) })     // 点赞评论
-		authGroup.POST("/comments/:id/unlike", func(c *gin.Context) { unlikeCommentHandler(c, db) }) // 取消点赞评
-		authGroup.POST("/friend/request", func(c *gin.Context) { SendFriendRequest(c, db) })
-		authGroup.POST("/friend/accept", AcceptFriendRequest)
-		authGroup.POST("/friend/delete", DeleteFriendRequest)
-		authGroup.GET("/friend/list", GetAllFriends)
-		authGroup.GET("/friend/getrequests", GetAllReceivedFriendRequests)
-		authGroup.POST("/avatar/upload", func(c *gin.Context) { UploadAvatar(c, db) })
-		authGroup.POST("/blessings", SendBlessings)                // 发送祝福
-		authGroup.GET("/blessings/sent", GetSentBlessings)         // 查询自己发出的祝福
-		authGroup.GET("/blessings/received", GetReceivedBlessings) // 查询自己收到的祝福
-		authGroup.GET("/ws", wsHandler)
-		authGroup.GET("/blessings/get", ReceiveByLink)
-		authGroup.POST("blessings/share", ShareBlessings)
-	}
-	r.Run(":8080")
+		authGroup.POST("/auth/logout", a.logoutHandler)
+		authGroup.POST("/auth/logout-all", a.logoutAllHandler)
+		authGroup.GET("/sessions", a.listSessionsHandler)
+		authGroup.DELETE("/sessions/:id", a.revokeSessionHandler)
+		authGroup.PUT("/password", a.changePasswordHandler)
+		authGroup.GET("/2fa", a.twoFactorStatusHandler)
+		authGroup.POST("/2fa/setup", a.twoFactorSetupHandler)
+		authGroup.POST("/2fa/enable", a.twoFactorEnableHandler)
+		authGroup.POST("/2fa/disable", a.twoFactorDisableHandler)
+		authGroup.POST("/2fa/recovery-codes", a.twoFactorRecoveryCodesHandler)
+		authGroup.PUT("/profile", a.updateProfileHandler)
+		authGroup.PATCH("/profile", a.updateProfileHandler)
+		authGroup.GET("/profile/privacy", a.getPrivacyHandler)
+		authGroup.PUT("/profile/privacy", a.updatePrivacyHandler)
+		authGroup.DELETE("/account", a.deleteAccountHandler)
+		authGroup.POST("/account/export", a.requestExportHandler)
+		authGroup.GET("/account/exports", a.listExportsHandler)
+		authGroup.GET("/account/export/:id", a.downloadExportHandler)
+		authGroup.POST("/posts", a.createPostHandler)
+		authGroup.GET("/posts", a.getPostsHandler)
+		authGroup.GET("/posts/discover", a.getPostsHandler) // 与 /posts 相同
+		authGroup.GET("/feed", a.getFeedHandler)
+		authGroup.GET("/users/:id/posts", a.getUserPostsHandler)
+		authGroup.PUT("/posts/:id", a.updatePostHandler)
+		authGroup.DELETE("/posts/:id", a.deletePostHandler)
+		authGroup.GET("/posts/:id/history", a.getPostHistoryHandler)
+		authGroup.POST("/posts/:id/like", a.likePostHandler)
+		authGroup.POST("/posts/:id/unlike", a.unlikePostHandler)
+		authGroup.GET("/posts/liked", a.getLikedPostsHandler) // 查询某人已点赞的帖子
+		authGroup.POST("/posts/:id/comments", a.createCommentHandler)
+		authGroup.GET("/posts/:id/comments", a.getCommentsHandler)
+		authGroup.POST("/comments/:id/like", a.likeCommentHandler)     // 点赞评论
+		authGroup.POST("/comments/:id/unlike", a.unlikeCommentHandler) // 取消点赞评
+		authGroup.POST("/friend/request", a.SendFriendRequest)
+		authGroup.POST("/friend/accept", a.AcceptFriendRequest)
+		authGroup.POST("/friend/delete", a.DeleteFriendRequest)
+		authGroup.GET("/friend/list", a.GetAllFriends)
+		authGroup.GET("/friend/getrequests", a.GetAllReceivedFriendRequests)
+		authGroup.GET("/users/search", a.rateLimit("user_search", a.cfg.RateLimit.UserSearch), a.searchUsersHandler)
+		authGroup.POST("/avatar/upload", a.UploadAvatar)
+		authGroup.POST("/blessings", a.SendBlessings)                // 发送祝福
+		authGroup.GET("/blessings/sent", a.GetSentBlessings)         // 查询自己发出的祝福
+		authGroup.GET("/blessings/received", a.GetReceivedBlessings) // 查询自己收到的祝福
+		authGroup.GET("/ws", a.wsHandler)
+		authGroup.GET("/blessings/get", a.ReceiveByLink)
+		authGroup.POST("blessings/share", a.ShareBlessings)
+		authGroup.POST("/reports", a.createReportHandler)
+	}
+
+	// 管理后台，版主可以处理内容和普通用户，管理员还可以修改角色
+	adminGroup := r.Group("/admin")
+	adminGroup.Use(a.authMiddleware, requireRole(RoleModerator))
+	{
+		adminGroup.GET("/users", a.adminListUsersHandler)
+		adminGroup.POST("/users/:id/ban", a.adminBanUserHandler)
+		adminGroup.DELETE("/users/:id/ban", a.adminUnbanUserHandler)
+		adminGroup.PUT("/users/:id/role", requireRole(RoleAdmin), a.adminSetRoleHandler)
+		adminGroup.DELETE("/posts/:id", a.adminDeletePostHandler)
+		adminGroup.DELETE("/comments/:id", a.adminDeleteCommentHandler)
+		adminGroup.DELETE("/blessings/:id", a.adminDeleteBlessingHandler)
+		adminGroup.GET("/reports", a.adminListReportsHandler)
+		adminGroup.POST("/reports/:id/resolve", a.adminResolveReportHandler)
+	}
+	return r
 }
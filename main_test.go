@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 集成测试环境：内存 SQLite + 进程内 Redis + 完整路由
+type testServer struct {
+	t      *testing.T
+	cfg    Config
+	app    *App
+	db     *gorm.DB
+	redis  *miniredis.Miniredis
+	server *httptest.Server
+}
+
+// 接口统一响应
+type apiResponse struct {
+	Status int             `json:"-"`
+	Header http.Header     `json:"-"`
+	Code   int             `json:"code"`
+	Data   json.RawMessage `json:"data"`
+	Msg    string          `json:"msg"`
+	Error  *struct {
+		Code    string       `json:"code"`
+		Details []FieldError `json:"details"`
+	} `json:"error"`
+}
+
+// opts 可在启动前调整配置
+func newTestServer(t *testing.T, opts ...func(*Config)) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := DefaultConfig()
+	cfg.JWT.Secret = "test-secret"
+	cfg.Upload.Dir = t.TempDir()
+	cfg.Export.Dir = t.TempDir()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	conn := newTestDB(t)
+	if _, err := NewMigrator(conn).Up(); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	mr := miniredis.RunT(t)
+	cfg.Redis.Addr = mr.Addr()
+	rdb := InitRedis(cfg.Redis)
+
+	app, err := NewApp(cfg, conn, rdb)
+	if err != nil {
+		t.Fatalf("初始化应用失败: %v", err)
+	}
+	t.Cleanup(func() { app.Close(context.Background()) })
+	// 测试不经过 Serve，单独启动导出任务，在关闭资源前停止
+	ctx, cancel := context.WithCancel(context.Background())
+	worker := make(chan struct{})
+	go func() {
+		defer close(worker)
+		app.exports.run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-worker
+	})
+	srv := httptest.NewServer(app.Router())
+	t.Cleanup(srv.Close)
+
+	return &testServer{t: t, cfg: cfg, app: app, db: conn, redis: mr, server: srv}
+}
+
+// 打开一个未执行迁移的内存 SQLite 数据库
+func newTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	conn, err := OpenDatabase(DatabaseConfig{Driver: DriverSQLite, DSN: ":memory:"})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return conn
+}
+
+// 发送 JSON 请求并解析统一响应
+func (ts *testServer) do(method, path, token string, body interface{}) apiResponse {
+	ts.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			ts.t.Fatalf("序列化请求失败: %v", err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, ts.server.URL+path, reader)
+	if err != nil {
+		ts.t.Fatalf("构造请求失败: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return ts.send(req, token)
+}
+
+func (ts *testServer) send(req *http.Request, token string) apiResponse {
+	ts.t.Helper()
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		ts.t.Fatalf("%s %s 请求失败: %v", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	var out apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		ts.t.Fatalf("%s %s 响应解析失败: %v", req.Method, req.URL.Path, err)
+	}
+	out.Status = resp.StatusCode
+	out.Header = resp.Header
+	return out
+}
+
+// 注册并登录，返回用户ID和令牌
+func (ts *testServer) signup(userName string) (int, string) {
+	ts.t.Helper()
+	resp := ts.do(http.MethodPost, "/register", "", gin.H{"userName": userName, "password": "password123"})
+	if resp.Code != http.StatusOK {
+		ts.t.Fatalf("注册 %s 失败: %s", userName, resp.Msg)
+	}
+	var login struct {
+		Token string `json:"token"`
+		User  struct {
+			ID int `json:"id"`
+		} `json:"user"`
+	}
+	mustDecode(ts.t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": userName, "password": "password123"}), &login)
+	return login.User.ID, login.Token
+}
+
+// 建立好友关系
+func (ts *testServer) befriend(fromToken, toToken string, toID int) {
+	ts.t.Helper()
+	expectOK(ts.t, ts.do(http.MethodPost, "/friend/request", fromToken, gin.H{"to_id": toID}))
+	var requests struct {
+		Requests []struct {
+			RequestID int `json:"request_id"`
+		} `json:"requests"`
+	}
+	mustDecode(ts.t, ts.do(http.MethodGet, "/friend/getrequests", toToken, nil), &requests)
+	if len(requests.Requests) == 0 {
+		ts.t.Fatal("没有收到好友请求")
+	}
+	expectOK(ts.t, ts.do(http.MethodPost, "/friend/accept", toToken, gin.H{"request_id": requests.Requests[0].RequestID}))
+}
+
+func expectOK(t *testing.T, resp apiResponse) {
+	t.Helper()
+	if resp.Code != http.StatusOK {
+		t.Fatalf("期望成功，实际 code=%d msg=%s", resp.Code, resp.Msg)
+	}
+}
+
+func expectCode(t *testing.T, resp apiResponse, code int) {
+	t.Helper()
+	if resp.Code != code {
+		t.Fatalf("期望 code=%d，实际 code=%d msg=%s", code, resp.Code, resp.Msg)
+	}
+}
+
+// 校验错误响应中的错误码
+func expectError(t *testing.T, resp apiResponse, code string) {
+	t.Helper()
+	if resp.Error == nil || resp.Error.Code != code {
+		t.Fatalf("期望错误码 %s，实际 code=%d msg=%s error=%+v", code, resp.Code, resp.Msg, resp.Error)
+	}
+}
+
+func mustDecode(t *testing.T, resp apiResponse, v interface{}) {
+	t.Helper()
+	expectOK(t, resp)
+	if err := json.Unmarshal(resp.Data, v); err != nil {
+		t.Fatalf("解析 data 失败: %v, data=%s", err, resp.Data)
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	ts := newTestServer(t)
+
+	expectCode(t, ts.do(http.MethodGet, "/posts", "", nil), http.StatusUnauthorized)
+	expectCode(t, ts.do(http.MethodGet, "/posts", "not-a-token", nil), http.StatusUnauthorized)
+	expectError(t, ts.do(http.MethodGet, "/posts", "", nil), "TOKEN_MISSING")
+	expectError(t, ts.do(http.MethodGet, "/posts", "not-a-token", nil), "TOKEN_INVALID")
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	ts := newTestServer(t)
+
+	// 选择一个空闲端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	app := *ts.app
+	app.cfg.Server.Addr = addr
+	app.cfg.Server.ShutdownTimeout = 3 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Serve(ctx) }()
+
+	eventually(t, func() bool {
+		resp, err := http.Get("http://" + addr + "/posts")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return true
+	})
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("优雅关闭失败: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("服务未在超时时间内退出")
+	}
+	if _, err := http.Get("http://" + addr + "/posts"); err == nil {
+		t.Fatal("关闭后不应再接受连接")
+	}
+}
@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 	"log"
 	"net/http"
 	"strconv"
@@ -28,46 +31,103 @@ type ChatMessage struct {
 	CreatedAt  time.Time `gorm:"index"`
 }
 
-var (
-	clients  = sync.Map{} // 在线用户连接池 map[userID]*websocket.Conn
-	upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+const (
+	writeWait         = 10 * time.Second   // 单次写入的超时时间
+	offlineTTL        = 7 * 24 * time.Hour // 离线消息保留时间
+	offlineQueueSize  = 1024               // 待写入 Redis 的离线消息缓冲
+	offlineKeyPrefix  = "offline:"
+	closeFrameMessage = "服务器正在关闭"
 )
 
-// WebSocket处理
-func wsHandler(c *gin.Context) {
-	userID := c.MustGet("userID").(int)
-	// 升级WebSocket连接
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+var ErrHubClosed = errors.New("聊天服务已关闭")
+
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+// 在线连接，gorilla/websocket 不支持并发写，需要加锁
+type client struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (cl *client) write(messageType int, data []byte) error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return cl.conn.WriteMessage(messageType, data)
+}
+
+func (cl *client) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
 	if err != nil {
-		log.Printf("WebSocket升级失败: %v", err)
-		return
+		return err
 	}
-	defer conn.Close()
+	return cl.write(websocket.TextMessage, data)
+}
 
-	// 注册连接并处理离线消息
-	clients.Store(userID, conn)
-	defer clients.Delete(userID)
-	go pullOfflineMessages(userID, conn) // 拉取离线消息
-	// 处理消息
-	for {
-		_, msgBytes, err := conn.ReadMessage()
-		if err != nil {
-			break
-		}
+// 聊天中心，管理在线连接并负责消息的持久化和投递
+type Hub struct {
+	db  *gorm.DB
+	rdb *redis.Client
 
-		var msg Message
-		if err := json.Unmarshal(msgBytes, &msg); err != nil {
-			continue
-		}
-		msg.FROM = userID
-		handleIncomingMessage(userID, msg)
+	mu      sync.RWMutex
+	clients map[int]map[*client]struct{} // 在线用户连接池，同一用户可以有多个连接
+	closing bool
+	conns   sync.WaitGroup // 仍在处理中的连接
+
+	offline     chan Message // 待写入 Redis 的离线消息
+	offlineDone chan struct{}
+}
+
+func NewHub(db *gorm.DB, rdb *redis.Client) *Hub {
+	h := &Hub{
+		db:          db,
+		rdb:         rdb,
+		clients:     make(map[int]map[*client]struct{}),
+		offline:     make(chan Message, offlineQueueSize),
+		offlineDone: make(chan struct{}),
+	}
+	go h.writeOffline()
+	return h
+}
+
+// 用户是否在线
+func (h *Hub) Online(userID int) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients[userID]) > 0
+}
+
+// 注册用户连接，关闭过程中拒绝新的连接
+func (h *Hub) Register(userID int, conn *websocket.Conn) (*client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing {
+		return nil, ErrHubClosed
+	}
+	cl := &client{conn: conn}
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*client]struct{})
+	}
+	h.clients[userID][cl] = struct{}{}
+	h.conns.Add(1)
+	return cl, nil
+}
+
+// 注销用户连接，不影响该用户的其他连接
+func (h *Hub) Unregister(userID int, cl *client) {
+	h.mu.Lock()
+	delete(h.clients[userID], cl)
+	if len(h.clients[userID]) == 0 {
+		delete(h.clients, userID)
 	}
+	h.mu.Unlock()
+	h.conns.Done()
 }
 
 // 处理收到的消息
-func handleIncomingMessage(senderID int, msg Message) {
+func (h *Hub) Deliver(senderID int, msg Message) {
 	// 持久化到数据库
-	db.Create(&ChatMessage{
+	h.db.Create(&ChatMessage{
 		SenderID:   senderID,
 		ReceiverID: msg.To,
 		Content:    msg.Content,
@@ -75,28 +135,137 @@ func handleIncomingMessage(senderID int, msg Message) {
 	})
 
 	// 检查接收方是否在线
-	if targetConn, ok := clients.Load(msg.To); ok {
-		// 在线：直接发送
-		targetConn.(*websocket.Conn).WriteJSON(msg)
-	} else {
-		// 离线：存入Redis List
+	h.mu.RLock()
+	targets := make([]*client, 0, len(h.clients[msg.To]))
+	for cl := range h.clients[msg.To] {
+		targets = append(targets, cl)
+	}
+	h.mu.RUnlock()
+	// 在线：发送到接收方的所有连接，至少一个成功即可
+	delivered := false
+	for _, target := range targets {
+		if err := target.writeJSON(msg); err == nil {
+			delivered = true
+		}
+	}
+	if delivered {
+		return
+	}
+	// 离线或发送失败：交给后台写入 Redis List
+	h.offline <- msg
+}
+
+// 将离线消息写入 Redis，队列关闭后写完剩余消息再退出
+func (h *Hub) writeOffline() {
+	defer close(h.offlineDone)
+	for msg := range h.offline {
 		msgData, _ := json.Marshal(msg)
-		rdb.RPush(context.Background(), "offline:"+strconv.Itoa(msg.To), msgData)
-		rdb.Expire(context.Background(), "offline:"+strconv.Itoa(msg.To), 7*24*time.Hour)
+		key := offlineKeyPrefix + strconv.Itoa(msg.To)
+		ctx := context.Background()
+		if err := h.rdb.RPush(ctx, key, msgData).Err(); err != nil {
+			log.Printf("写入离线消息失败: %v", err)
+			continue
+		}
+		h.rdb.Expire(ctx, key, offlineTTL)
 	}
 }
 
 // 拉取离线消息
-func pullOfflineMessages(userID int, conn *websocket.Conn) {
-	key := "offline:" + strconv.Itoa(userID)
-	messages, err := rdb.LRange(context.Background(), key, 0, -1).Result()
+func (h *Hub) PullOffline(userID int, cl *client) {
+	key := offlineKeyPrefix + strconv.Itoa(userID)
+	messages, err := h.rdb.LRange(context.Background(), key, 0, -1).Result()
 	if err != nil || len(messages) == 0 {
 		return
 	}
 
 	// 发送并清空
 	for _, msg := range messages {
-		conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		cl.write(websocket.TextMessage, []byte(msg))
+	}
+	h.rdb.Del(context.Background(), key)
+}
+
+// 关闭聊天中心：拒绝新连接，向所有在线用户发送关闭帧并等待连接退出，
+// 最后把尚未写入的离线消息刷入 Redis。ctx 到期后强制断开剩余连接
+func (h *Hub) Close(ctx context.Context) error {
+	h.mu.Lock()
+	if h.closing {
+		h.mu.Unlock()
+		return nil
+	}
+	h.closing = true
+	var clients []*client
+	for _, conns := range h.clients {
+		for cl := range conns {
+			clients = append(clients, cl)
+		}
+	}
+	h.mu.Unlock()
+
+	closeFrame := websocket.FormatCloseMessage(websocket.CloseGoingAway, closeFrameMessage)
+	for _, cl := range clients {
+		cl.conn.WriteControl(websocket.CloseMessage, closeFrame, time.Now().Add(writeWait))
+	}
+
+	// 等待客户端回应关闭帧后连接处理协程自行退出
+	drained := make(chan struct{})
+	go func() {
+		h.conns.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		for _, cl := range clients {
+			cl.conn.Close()
+		}
+		<-drained
+	}
+
+	// 所有连接退出后不会再有新的离线消息
+	close(h.offline)
+	select {
+	case <-h.offlineDone:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return err
+}
+
+// WebSocket处理
+func (a *App) wsHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	// 升级WebSocket连接
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// 注册连接并处理离线消息
+	cl, err := a.hub.Register(userID, conn)
+	if err != nil {
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseGoingAway, closeFrameMessage), time.Now().Add(writeWait))
+		return
+	}
+	defer a.hub.Unregister(userID, cl)
+	go a.hub.PullOffline(userID, cl) // 拉取离线消息
+	// 处理消息
+	for {
+		_, msgBytes, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		var msg Message
+		if err := json.Unmarshal(msgBytes, &msg); err != nil {
+			continue
+		}
+		msg.FROM = userID
+		a.hub.Deliver(userID, msg)
 	}
-	rdb.Del(context.Background(), key)
 }
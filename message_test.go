@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 以指定用户身份建立聊天连接
+func (ts *testServer) dialChat(token string) *websocket.Conn {
+	ts.t.Helper()
+	url := "ws" + strings.TrimPrefix(ts.server.URL, "http") + "/ws"
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Authorization": {token}})
+	if err != nil {
+		ts.t.Fatalf("建立 WebSocket 连接失败: %v", err)
+	}
+	ts.t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("读取消息失败: %v", err)
+	}
+	return msg
+}
+
+// 等待条件成立，用于观察异步写入的结果
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("等待超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestChatOnlineDelivery(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+
+	bobConn := ts.dialChat(bob)
+	aliceConn := ts.dialChat(alice)
+	// 等待双方连接注册完成
+	eventually(t, func() bool { return ts.app.hub.Online(aliceID) && ts.app.hub.Online(bobID) })
+
+	if err := aliceConn.WriteJSON(Message{To: bobID, Content: "在吗"}); err != nil {
+		t.Fatal(err)
+	}
+	msg := readMessage(t, bobConn)
+	if msg.FROM != aliceID || msg.Content != "在吗" {
+		t.Fatalf("收到的消息不符: %+v", msg)
+	}
+
+	var count int64
+	eventually(t, func() bool {
+		ts.db.Model(&ChatMessage{}).Where("sender_id = ? AND receiver_id = ?", aliceID, bobID).Count(&count)
+		return count == 1
+	})
+}
+
+func TestChatOfflineMessages(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+
+	aliceConn := ts.dialChat(alice)
+	if err := aliceConn.WriteJSON(Message{To: bobID, Content: "新年好"}); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "offline:" + strconv.Itoa(bobID)
+	eventually(t, func() bool { return ts.redis.Exists(key) })
+
+	msg := readMessage(t, ts.dialChat(bob))
+	if msg.FROM != aliceID || msg.Content != "新年好" {
+		t.Fatalf("离线消息不符: %+v", msg)
+	}
+	eventually(t, func() bool { return !ts.redis.Exists(key) })
+}
+
+func TestChatShutdown(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	bobID, _ := ts.signup("bob")
+
+	aliceConn := ts.dialChat(alice)
+	if err := aliceConn.WriteJSON(Message{To: bobID, Content: "晚安"}); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	eventually(t, func() bool {
+		ts.db.Model(&ChatMessage{}).Count(&count)
+		return count == 1
+	})
+
+	closed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		closed <- ts.app.hub.Close(ctx)
+	}()
+
+	// 客户端应收到 1001 关闭帧
+	aliceConn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, _, err := aliceConn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("期望收到 GoingAway 关闭帧，实际 %v", err)
+	}
+	if err := <-closed; err != nil {
+		t.Fatalf("关闭聊天服务失败: %v", err)
+	}
+
+	// 关闭完成时离线消息已写入 Redis
+	if !ts.redis.Exists("offline:" + strconv.Itoa(bobID)) {
+		t.Fatal("离线消息未刷入 Redis")
+	}
+	if _, err := ts.app.hub.Register(bobID, nil); !errors.Is(err, ErrHubClosed) {
+		t.Fatalf("关闭后应拒绝新连接，实际 %v", err)
+	}
+}
+
+// 同一用户有多个连接且客户端不回应关闭帧时，到期后应断开所有连接并返回
+func TestChatShutdownMultipleConnections(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+
+	conns := []*websocket.Conn{ts.dialChat(alice), ts.dialChat(alice), ts.dialChat(bob), ts.dialChat(bob)}
+	eventually(t, func() bool { return ts.app.hub.Online(aliceID) && ts.app.hub.Online(bobID) })
+
+	// 两个连接都能收到消息
+	if err := conns[2].WriteJSON(Message{To: aliceID, Content: "新年好"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range conns[:2] {
+		if msg := readMessage(t, conn); msg.Content != "新年好" {
+			t.Fatalf("收到的消息不符: %+v", msg)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	closed := make(chan error, 1)
+	go func() { closed <- ts.app.hub.Close(ctx) }()
+	select {
+	case err := <-closed:
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("关闭聊天服务失败: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("关闭聊天服务超时")
+	}
+
+	// 所有连接都已断开
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if ne, ok := err.(interface{ Timeout() bool }); ok && ne.Timeout() {
+					t.Fatalf("连接 %d 未被断开", i)
+				}
+				break
+			}
+		}
+	}
+	if ts.app.hub.Online(aliceID) || ts.app.hub.Online(bobID) {
+		t.Fatal("关闭后不应有在线用户")
+	}
+}
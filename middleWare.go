@@ -1,33 +1,21 @@
 package main
 
 import (
-	"fmt"
-	"net/http"
-	"time"
+	"errors"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
-	"gorm.io/gorm"
 )
 
-var jwtSecret = []byte("your_secret_key")
-
-// 生成Token
-func generateToken(userID int) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
-	return token.SignedString(jwtSecret)
-}
-
 // 密码哈希
 func hashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	return string(bytes), err
 }
 
+// 用户不存在时用于比对的哈希，与 hashPassword 的代价相同，使登录耗时不因用户名是否存在而不同
+const dummyPasswordHash = "$2a$10$MYiIdlTYCYNdYd6Ao.XwmeRKbP3vKksMa2YS.ZHAkaqjv3uz5IVr2"
+
 // 验证密码
 func checkPassword(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
@@ -35,42 +23,44 @@ func checkPassword(password, hash string) bool {
 }
 
 // 认证中间件
-func authMiddleware(c *gin.Context, db *gorm.DB) {
+func (a *App) authMiddleware(c *gin.Context) {
 	tokenString := c.GetHeader("Authorization")
 	if tokenString == "" {
-		ResponseFAIL(c, http.StatusUnauthorized, "缺少认证令牌")
-		c.Abort()
+		AbortWithError(c, ErrTokenMissing)
 		return
 	}
 
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("无效的签名方法")
-		}
-		return jwtSecret, nil
-	})
-
-	if err != nil || !token.Valid {
-		ResponseFAIL(c, http.StatusUnauthorized, "无效的令牌")
-		c.Abort()
+	claims, err := a.auth.Verify(c.Request.Context(), tokenString, requestMeta(c))
+	if err != nil {
+		AbortWithError(c, err)
 		return
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		ResponseFAIL(c, http.StatusUnauthorized, "无效的令牌声明")
-		c.Abort()
+	userID := claims.UserID
+	user, err := a.users.Get(userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			err = ErrAuthUserGone
+		}
+		AbortWithError(c, err)
 		return
 	}
-
-	userID := int(claims["user_id"].(float64))
-	var user User
-	if err := db.First(&user, userID).Error; err != nil {
-		ResponseFAIL(c, http.StatusUnauthorized, "用户不存在")
-		c.Abort()
+	if user.BannedAt != nil {
+		AbortWithError(c, ErrAccountBanned)
 		return
 	}
 
 	c.Set("userID", userID)
+	c.Set(roleKey, normalizeRole(user.Role))
+	c.Set(claimsKey, claims)
 	c.Next()
 }
+
+// 可选认证：未携带令牌时按匿名用户继续处理，携带令牌时与 authMiddleware 相同
+func (a *App) optionalAuthMiddleware(c *gin.Context) {
+	if c.GetHeader("Authorization") == "" {
+		c.Next()
+		return
+	}
+	a.authMiddleware(c)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 一次版本化的表结构变更
+// 注意：MySQL 的 DDL 会隐式提交事务，Down 需要能够在 Up 执行到一半失败后安全执行
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// 迁移状态
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+var ErrNoMigrationToRollback = errors.New("没有可回滚的迁移")
+
+// 迁移执行器
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) *Migrator {
+	return newMigrator(db, migrations)
+}
+
+func newMigrator(db *gorm.DB, list []Migration) *Migrator {
+	sorted := append([]Migration(nil), list...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{db: db, migrations: sorted}
+}
+
+// 确保 schema_migrations 表存在
+func (m *Migrator) ensureTable() error {
+	if err := m.db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("创建 schema_migrations 表失败: %w", err)
+	}
+	return nil
+}
+
+// 已执行的版本，schema_migrations 表不存在时视为全部未执行
+func (m *Migrator) applied() (map[int]SchemaMigration, error) {
+	if !m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return map[int]SchemaMigration{}, nil
+	}
+	var records []SchemaMigration
+	if err := m.db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// 执行所有未执行的迁移，返回本次执行的迁移
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := mig.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("执行迁移 %d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+// 回滚最近一次执行的迁移
+func (m *Migrator) Down() (*Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := mig.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, mig.Version).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("回滚迁移 %d_%s 失败: %w", mig.Version, mig.Name, err)
+		}
+		return &mig, nil
+	}
+	return nil, ErrNoMigrationToRollback
+}
+
+// 所有迁移的执行状态
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			appliedAt := r.AppliedAt
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// 未执行的迁移数量
+func (m *Migrator) Pending() (int, error) {
+	status, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// 执行 migrate 子命令：migrate up|down|status
+func runMigrate(m *Migrator, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("用法: migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := m.Up()
+		for _, mig := range done {
+			fmt.Fprintf(out, "已执行 %d_%s\n", mig.Version, mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(out, "没有待执行的迁移")
+		}
+	case "down":
+		mig, err := m.Down()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "已回滚 %d_%s\n", mig.Version, mig.Name)
+	case "status":
+		status, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("未知的迁移命令 %q，可用: up|down|status", args[0])
+	}
+	return nil
+}
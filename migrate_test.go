@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMigrateUpDownStatus(t *testing.T) {
+	conn := newTestDB(t)
+	m := NewMigrator(conn)
+
+	if pending, err := m.Pending(); err != nil || pending != len(migrations) {
+		t.Fatalf("初始应全部待执行: pending=%d err=%v", pending, err)
+	}
+
+	done, err := m.Up()
+	if err != nil || len(done) != len(migrations) {
+		t.Fatalf("执行迁移失败: done=%d err=%v", len(done), err)
+	}
+	if done, err := m.Up(); err != nil || len(done) != 0 {
+		t.Fatalf("重复执行不应有新的迁移: done=%d err=%v", len(done), err)
+	}
+	if !conn.Migrator().HasTable("users") || !conn.Migrator().HasIndex("likes", "idx_likes_post_user") {
+		t.Fatal("迁移后缺少表或索引")
+	}
+
+	for range migrations {
+		if _, err := m.Down(); err != nil {
+			t.Fatalf("回滚失败: %v", err)
+		}
+	}
+	if _, err := m.Down(); !errors.Is(err, ErrNoMigrationToRollback) {
+		t.Fatalf("全部回滚后应返回 ErrNoMigrationToRollback，实际 %v", err)
+	}
+	if conn.Migrator().HasTable("users") {
+		t.Fatal("回滚后 users 表应被删除")
+	}
+
+	var out bytes.Buffer
+	if err := runMigrate(m, []string{"status"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "pending") != len(migrations) {
+		t.Fatalf("状态输出不符:\n%s", out.String())
+	}
+	if err := runMigrate(m, []string{"sideways"}, &out); err == nil {
+		t.Fatal("未知命令应返回错误")
+	}
+}
+
+func TestMigrateUniqueIndexesBackfill(t *testing.T) {
+	conn := newTestDB(t)
+	if _, err := newMigrator(conn, migrations[:1]).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧数据中存在重复点赞和重复好友关系
+	conn.Omit("Visibility", "EditedAt", "DeletedAt").Create(&Post{UserID: 1, Content: "hi", LikeCount: 5}) // 版本 1 中没有的列
+	conn.Create(&Comment{PostID: 1, UserID: 1, Content: "c", LikeCount: 3})
+	for i := 0; i < 3; i++ {
+		conn.Create(&Like{PostID: 1, UserID: 2})
+		conn.Create(&CommentLike{CommentID: 1, UserID: 2})
+		conn.Create(&FriendRelationship{UserID: 1, FriendID: 2})
+	}
+
+	if _, err := NewMigrator(conn).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	var likes, commentLikes, relations int64
+	conn.Model(&Like{}).Count(&likes)
+	conn.Model(&CommentLike{}).Count(&commentLikes)
+	conn.Model(&FriendRelationship{}).Count(&relations)
+	if likes != 1 || commentLikes != 1 || relations != 1 {
+		t.Fatalf("重复数据未清理: likes=%d commentLikes=%d relations=%d", likes, commentLikes, relations)
+	}
+
+	var post Post
+	var comment Comment
+	conn.First(&post, 1)
+	conn.First(&comment, 1)
+	if post.LikeCount != 1 || comment.LikeCount != 1 {
+		t.Fatalf("点赞数未重新统计: post=%d comment=%d", post.LikeCount, comment.LikeCount)
+	}
+	if post.Visibility != VisibilityPublic {
+		t.Fatalf("已有的说说应为公开: %q", post.Visibility)
+	}
+
+	if err := conn.Create(&Like{PostID: 1, UserID: 2}).Error; err == nil {
+		t.Fatal("唯一索引应阻止重复点赞")
+	}
+}
+
+func TestMigrateStructuredProfileBackfill(t *testing.T) {
+	conn := newTestDB(t)
+	if _, err := newMigrator(conn, migrations[:8]).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧数据中生日、性别和兴趣都是自由文本
+	legacy := []v1User{
+		{UserName: "alice", Password: "x", Birthday: "2004/2/10", Gender: "女", Interests: "书法，#Reading、reading; 篮球"},
+		{UserName: "bob", Password: "x", Birthday: "明天", Gender: "unknown", Interests: ""},
+	}
+	for i := range legacy {
+		if err := conn.Create(&legacy[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := newMigrator(conn, migrations[:9]).Up(); err != nil {
+		t.Fatal(err)
+	}
+	var alice, bob User
+	conn.First(&alice, legacy[0].ID)
+	conn.First(&bob, legacy[1].ID)
+	if alice.Birthday == nil || alice.Birthday.String() != "2004-02-10" || alice.Gender != GenderFemale {
+		t.Fatalf("alice 的生日或性别未转换: %v %q", alice.Birthday, alice.Gender)
+	}
+	if bob.Birthday != nil || bob.Gender != "" {
+		t.Fatalf("无法识别的值应清空: %v %q", bob.Birthday, bob.Gender)
+	}
+	tags, err := NewUserService(conn, nil).Interests(alice.ID)
+	if err != nil || len(tags) != 3 || tags[0] != "书法" || tags[1] != "reading" || tags[2] != "篮球" {
+		t.Fatalf("兴趣未拆分为标签: %q err=%v", tags, err)
+	}
+	if conn.Migrator().HasColumn("users", "interests") {
+		t.Fatal("旧的 interests 列应被删除")
+	}
+
+	// 回滚后兴趣恢复为文本
+	if _, err := newMigrator(conn, migrations[:9]).Down(); err != nil {
+		t.Fatal(err)
+	}
+	var restored v1User
+	conn.First(&restored, legacy[0].ID)
+	if restored.Interests != "书法,reading,篮球" || !strings.HasPrefix(restored.Birthday, "2004-02-10") {
+		t.Fatalf("回滚后数据不符: %+v", restored)
+	}
+}
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 所有迁移，按版本号递增追加，已发布的迁移不要修改
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_initial_tables",
+		Up: func(tx *gorm.DB) error {
+			// 使用当时的表结构快照，已有的 AutoMigrate 部署执行时只会补齐缺失的表和列
+			return tx.AutoMigrate(v1Tables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(v1Tables...)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "add_unique_indexes",
+		Up: func(tx *gorm.DB) error {
+			// 先清理重复数据，保留最早的一条，再重新统计点赞数
+			steps := []string{
+				"DELETE FROM likes WHERE id NOT IN (SELECT id FROM (SELECT MIN(id) AS id FROM likes GROUP BY post_id, user_id) AS keep)",
+				"DELETE FROM comment_likes WHERE id NOT IN (SELECT id FROM (SELECT MIN(id) AS id FROM comment_likes GROUP BY comment_id, user_id) AS keep)",
+				"DELETE FROM friend_relationships WHERE id NOT IN (SELECT id FROM (SELECT MIN(id) AS id FROM friend_relationships GROUP BY user_id, friend_id) AS keep)",
+				"UPDATE posts SET like_count = (SELECT COUNT(*) FROM likes WHERE likes.post_id = posts.id)",
+				"UPDATE comments SET like_count = (SELECT COUNT(*) FROM comment_likes WHERE comment_likes.comment_id = comments.id)",
+			}
+			for _, sql := range steps {
+				if err := tx.Exec(sql).Error; err != nil {
+					return err
+				}
+			}
+			for _, idx := range v2UniqueIndexes {
+				if tx.Migrator().HasIndex(idx.table, idx.name) {
+					continue
+				}
+				if err := tx.Exec("CREATE UNIQUE INDEX " + idx.name + " ON " + idx.table + " (" + idx.columns + ")").Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, idx := range v2UniqueIndexes {
+				if !tx.Migrator().HasIndex(idx.table, idx.name) {
+					continue
+				}
+				if err := tx.Migrator().DropIndex(idx.table, idx.name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 3,
+		Name:    "add_user_email",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&v3User{}, "Email") {
+				if err := tx.Migrator().AddColumn(&v3User{}, "Email"); err != nil {
+					return err
+				}
+			}
+			if tx.Migrator().HasIndex(&v3User{}, "idx_users_email") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&v3User{}, "idx_users_email")
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&v3User{}, "idx_users_email") {
+				if err := tx.Migrator().DropIndex(&v3User{}, "idx_users_email"); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasColumn(&v3User{}, "Email") {
+				return nil
+			}
+			return tx.Migrator().DropColumn(&v3User{}, "Email")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "add_roles_bans_reports",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{"Role", "BannedAt", "BanReason"} {
+				if tx.Migrator().HasColumn(&v4User{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&v4User{}, column); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&v4Report{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v4Report{}); err != nil {
+				return err
+			}
+			for _, column := range []string{"Role", "BannedAt", "BanReason"} {
+				if !tx.Migrator().HasColumn(&v4User{}, column) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&v4User{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 5,
+		Name:    "add_two_factor",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{"TOTPSecret", "TOTPEnabledAt"} {
+				if tx.Migrator().HasColumn(&v5User{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&v5User{}, column); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&v5RecoveryCode{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v5RecoveryCode{}); err != nil {
+				return err
+			}
+			for _, column := range []string{"TOTPSecret", "TOTPEnabledAt"} {
+				if !tx.Migrator().HasColumn(&v5User{}, column) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&v5User{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_user_purged_at",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&v6User{}, "PurgedAt") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&v6User{}, "PurgedAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&v6User{}, "PurgedAt") {
+				return nil
+			}
+			return tx.Migrator().DropColumn(&v6User{}, "PurgedAt")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_data_exports",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v7DataExport{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v7DataExport{})
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_profile_visibilities",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&v8ProfileVisibility{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&v8ProfileVisibility{})
+		},
+	},
+	{
+		Version: 9,
+		Name:    "structured_profile_fields",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&v9UserInterest{}); err != nil {
+				return err
+			}
+			// 先把旧的自由文本整理成新格式，无法识别的生日和性别清空
+			var users []v8User
+			if err := tx.Find(&users).Error; err != nil {
+				return err
+			}
+			for _, u := range users {
+				if err := tx.Model(&v8User{}).Where("id = ?", u.ID).Updates(map[string]interface{}{
+					"birthday": v9Birthday(u.Birthday),
+					"gender":   v9Gender(u.Gender),
+				}).Error; err != nil {
+					return err
+				}
+				for i, tag := range v9Interests(u.Interests) {
+					if err := tx.Create(&v9UserInterest{UserID: u.ID, Tag: tag, Position: i}).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if err := tx.Migrator().AlterColumn(&v9User{}, "Birthday"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&v8User{}, "Interests")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&v8User{}, "Interests"); err != nil {
+				return err
+			}
+			var interests []v9UserInterest
+			if err := tx.Order("user_id, position").Find(&interests).Error; err != nil {
+				return err
+			}
+			joined := map[int][]string{}
+			for _, i := range interests {
+				joined[i.UserID] = append(joined[i.UserID], i.Tag)
+			}
+			for userID, tags := range joined {
+				if err := tx.Model(&v8User{}).Where("id = ?", userID).Update("interests", strings.Join(tags, ",")).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().AlterColumn(&v8User{}, "Birthday"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&v9UserInterest{})
+		},
+	},
+	{
+		Version: 10,
+		Name:    "add_pagination_indexes",
+		Up:      func(tx *gorm.DB) error { return createIndexes(tx, v10PaginationIndexes) },
+		Down:    func(tx *gorm.DB) error { return dropIndexes(tx, v10PaginationIndexes) },
+	},
+	{
+		Version: 11,
+		Name:    "add_post_author_index",
+		Up:      func(tx *gorm.DB) error { return createIndexes(tx, v11PostAuthorIndexes) },
+		Down:    func(tx *gorm.DB) error { return dropIndexes(tx, v11PostAuthorIndexes) },
+	},
+	{
+		Version: 12,
+		Name:    "add_post_visibility",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&v12Post{}, "Visibility") {
+				if err := tx.Migrator().AddColumn(&v12Post{}, "Visibility"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&v12PostAudience{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v12PostAudience{}); err != nil {
+				return err
+			}
+			if !tx.Migrator().HasColumn(&v12Post{}, "Visibility") {
+				return nil
+			}
+			return tx.Migrator().DropColumn(&v12Post{}, "Visibility")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "add_post_edits_and_soft_delete",
+		Up: func(tx *gorm.DB) error {
+			for _, column := range []string{"EditedAt", "DeletedAt"} {
+				if tx.Migrator().HasColumn(&v13Post{}, column) {
+					continue
+				}
+				if err := tx.Migrator().AddColumn(&v13Post{}, column); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasIndex(&v13Post{}, "DeletedAt") {
+				if err := tx.Migrator().CreateIndex(&v13Post{}, "DeletedAt"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&v13PostEdit{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&v13PostEdit{}); err != nil {
+				return err
+			}
+			// 回滚后软删除的说说会重新出现，先将其彻底删除
+			if tx.Migrator().HasColumn(&v13Post{}, "DeletedAt") {
+				if err := tx.Exec("DELETE FROM posts WHERE deleted_at IS NOT NULL").Error; err != nil {
+					return err
+				}
+			}
+			if tx.Migrator().HasIndex(&v13Post{}, "DeletedAt") {
+				if err := tx.Migrator().DropIndex(&v13Post{}, "DeletedAt"); err != nil {
+					return err
+				}
+			}
+			for _, column := range []string{"EditedAt", "DeletedAt"} {
+				if !tx.Migrator().HasColumn(&v13Post{}, column) {
+					continue
+				}
+				if err := tx.Migrator().DropColumn(&v13Post{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// 版本 13 新增的说说修改时间和软删除时间
+type v13Post struct {
+	EditedAt  *time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (v13Post) TableName() string { return "posts" }
+
+// 版本 13 新增的说说修改记录表
+type v13PostEdit struct {
+	ID        int       `gorm:"primaryKey;autoIncrement"`
+	PostID    int       `gorm:"not null;index"`
+	EditorID  int       `gorm:"not null"`
+	Content   string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (v13PostEdit) TableName() string { return "post_edits" }
+
+// 版本 12 新增的说说可见范围，已有的说说为公开
+type v12Post struct {
+	Visibility string `gorm:"type:varchar(10);not null;default:public"`
+}
+
+func (v12Post) TableName() string { return "posts" }
+
+// 版本 12 新增的说说指定可见好友表
+type v12PostAudience struct {
+	PostID int `gorm:"primaryKey;autoIncrement:false"`
+	UserID int `gorm:"primaryKey;autoIncrement:false;index"`
+}
+
+func (v12PostAudience) TableName() string { return "post_audiences" }
+
+type indexDef struct{ table, name, columns string }
+
+// 创建不存在的索引
+func createIndexes(tx *gorm.DB, indexes []indexDef) error {
+	for _, idx := range indexes {
+		if tx.Migrator().HasIndex(idx.table, idx.name) {
+			continue
+		}
+		if err := tx.Exec("CREATE INDEX " + idx.name + " ON " + idx.table + " (" + idx.columns + ")").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(tx *gorm.DB, indexes []indexDef) error {
+	for _, idx := range indexes {
+		if !tx.Migrator().HasIndex(idx.table, idx.name) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(idx.table, idx.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 版本 11 新增的索引，用于动态和个人主页按作者查询说说
+var v11PostAuthorIndexes = []indexDef{
+	{"posts", "idx_posts_user_created_id", "user_id, created_at, id"},
+}
+
+// 版本 10 新增的索引，用于按 (created_at, id) 倒序的游标分页
+var v10PaginationIndexes = []indexDef{
+	{"posts", "idx_posts_created_id", "created_at, id"},
+	{"comments", "idx_comments_post_created_id", "post_id, created_at, id"},
+	{"blessings", "idx_blessings_sender_created_id", "sender_id, created_at, id"},
+	{"blessings", "idx_blessings_receiver_created_id", "receiver_id, created_at, id"},
+}
+
+// 版本 9 之前个人信息中的自由文本字段
+type v8User struct {
+	ID        int    `gorm:"primaryKey"`
+	Birthday  string `gorm:"type:varchar(50)"`
+	Gender    string `gorm:"type:varchar(10)"`
+	Interests string `gorm:"type:text"`
+}
+
+func (v8User) TableName() string { return "users" }
+
+// 版本 9 改为日期类型的生日
+type v9User struct {
+	Birthday *time.Time `gorm:"type:date"`
+}
+
+func (v9User) TableName() string { return "users" }
+
+// 版本 9 新增的兴趣标签表
+type v9UserInterest struct {
+	UserID   int    `gorm:"primaryKey;autoIncrement:false"`
+	Tag      string `gorm:"primaryKey;type:varchar(80)"`
+	Position int    `gorm:"not null;default:0"`
+}
+
+func (v9UserInterest) TableName() string { return "user_interests" }
+
+// 识别旧数据中常见的日期写法，返回 YYYY-MM-DD，无法识别或不合理时返回 nil
+func v9Birthday(s string) interface{} {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{"2006-1-2", "2006/1/2", "2006.1.2", "2006年1月2日", "20060102"} {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		if t.Year() < 1900 || t.After(time.Now()) {
+			return nil
+		}
+		return t.Format("2006-01-02")
+	}
+	return nil
+}
+
+func v9Gender(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "male", "m", "man", "男", "男性":
+		return "male"
+	case "female", "f", "woman", "女", "女性":
+		return "female"
+	case "other", "其他":
+		return "other"
+	}
+	return ""
+}
+
+// 按常见分隔符拆分旧的兴趣文本，最多保留 10 个标签，过长的标签丢弃
+func v9Interests(s string) []string {
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(",，、;；|/\n", r)
+	})
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, p := range parts {
+		tag := strings.ToLower(strings.Join(strings.Fields(strings.TrimLeft(strings.TrimSpace(p), "#＃")), " "))
+		if tag == "" || seen[tag] || utf8.RuneCountInString(tag) > 20 {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+		if len(tags) == 10 {
+			break
+		}
+	}
+	return tags
+}
+
+// 版本 8 新增的个人信息字段可见范围表
+type v8ProfileVisibility struct {
+	UserID     int    `gorm:"primaryKey;autoIncrement:false"`
+	Field      string `gorm:"primaryKey;type:varchar(32)"`
+	Visibility string `gorm:"type:varchar(10);not null"`
+}
+
+func (v8ProfileVisibility) TableName() string { return "profile_visibilities" }
+
+// 版本 7 新增的个人数据导出记录表
+type v7DataExport struct {
+	ID          int    `gorm:"primaryKey;autoIncrement"`
+	UserID      int    `gorm:"not null;index"`
+	Status      string `gorm:"type:varchar(20);not null;index"`
+	FileName    string `gorm:"type:varchar(255)"`
+	Size        int64
+	Error       string `gorm:"type:varchar(255)"`
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+	ExpiresAt   *time.Time `gorm:"index"`
+}
+
+func (v7DataExport) TableName() string { return "data_exports" }
+
+// 版本 6 新增的注销数据清除时间
+type v6User struct {
+	PurgedAt *time.Time
+}
+
+func (v6User) TableName() string { return "users" }
+
+// 版本 5 新增的两步验证字段
+type v5User struct {
+	TOTPSecret    string `gorm:"type:varchar(64)"`
+	TOTPEnabledAt *time.Time
+}
+
+func (v5User) TableName() string { return "users" }
+
+// 版本 5 新增的恢复码表
+type v5RecoveryCode struct {
+	ID        int    `gorm:"primaryKey;autoIncrement"`
+	UserID    int    `gorm:"not null;index"`
+	CodeHash  string `gorm:"type:varchar(100);not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (v5RecoveryCode) TableName() string { return "recovery_codes" }
+
+// 版本 4 新增的用户角色和封禁字段
+type v4User struct {
+	Role      string `gorm:"type:varchar(20);not null;default:user"`
+	BannedAt  *time.Time
+	BanReason string `gorm:"type:varchar(255)"`
+}
+
+func (v4User) TableName() string { return "users" }
+
+// 版本 4 新增的举报表
+type v4Report struct {
+	ID         int    `gorm:"primaryKey;autoIncrement"`
+	ReporterID int    `gorm:"not null;index"`
+	TargetType string `gorm:"type:varchar(20);not null;index:idx_reports_target"`
+	TargetID   int    `gorm:"not null;index:idx_reports_target"`
+	Reason     string `gorm:"type:text;not null"`
+	Status     string `gorm:"type:varchar(20);not null;default:open;index"`
+	ResolvedBy *int
+	ResolvedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (v4Report) TableName() string { return "reports" }
+
+// 版本 3 新增的用户邮箱，未设置时为 NULL，唯一索引不会冲突
+type v3User struct {
+	Email *string `gorm:"type:varchar(255);uniqueIndex:idx_users_email"`
+}
+
+func (v3User) TableName() string { return "users" }
+
+// 版本 2 新增的唯一索引
+var v2UniqueIndexes = []struct{ table, name, columns string }{
+	{"likes", "idx_likes_post_user", "post_id, user_id"},
+	{"comment_likes", "idx_comment_likes_comment_user", "comment_id, user_id"},
+	{"friend_relationships", "idx_friend_relationships_user_friend", "user_id, friend_id"},
+}
+
+// 版本 1 的表结构快照，与模型解耦，后续模型变更不会影响该迁移
+var v1Tables = []interface{}{
+	&v1Avatar{}, &v1Blessing{}, &v1ChatMessage{}, &v1CommentLike{}, &v1Comment{},
+	&v1FriendRelationship{}, &v1FriendRequest{}, &v1Like{}, &v1Post{}, &v1User{},
+}
+
+type v1User struct {
+	ID        int    `gorm:"primaryKey;autoIncrement"`
+	UserName  string `gorm:"type:varchar(100);not null;unique"`
+	Password  string `gorm:"type:varchar(100);not null"`
+	NickName  string `gorm:"type:varchar(100)"`
+	Age       int    `gorm:"default:0"`
+	Birthday  string `gorm:"type:varchar(50)"`
+	Gender    string `gorm:"type:varchar(10)"`
+	Interests string `gorm:"type:text"`
+	Status    string `gorm:"type:varchar(50)"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1User) TableName() string { return "users" }
+
+type v1Post struct {
+	ID        int    `gorm:"primaryKey;autoIncrement"`
+	UserID    int    `gorm:"not null"`
+	Content   string `gorm:"type:text;not null"`
+	LikeCount int    `gorm:"default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (v1Post) TableName() string { return "posts" }
+
+type v1Like struct {
+	ID        int `gorm:"primaryKey;autoIncrement"`
+	PostID    int `gorm:"not null"`
+	UserID    int `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (v1Like) TableName() string { return "likes" }
+
+type v1Comment struct {
+	ID        int    `gorm:"primaryKey;autoIncrement"`
+	PostID    int    `gorm:"not null"`
+	UserID    int    `gorm:"not null"`
+	Content   string `gorm:"type:text;not null"`
+	LikeCount int    `gorm:"default:0"`
+	ParentID  *int   `gorm:"default:null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (v1Comment) TableName() string { return "comments" }
+
+type v1CommentLike struct {
+	ID        int `gorm:"primaryKey;autoIncrement"`
+	CommentID int `gorm:"not null"`
+	UserID    int `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (v1CommentLike) TableName() string { return "comment_likes" }
+
+type v1FriendRequest struct {
+	ID             int  `gorm:"primary_key"`
+	FromID         int  `gorm:"not null"`
+	ToID           int  `gorm:"not null"`
+	AcceptedStatus bool `gorm:"default:false"`
+}
+
+func (v1FriendRequest) TableName() string { return "friend_requests" }
+
+type v1FriendRelationship struct {
+	ID       int `gorm:"primary_key"`
+	UserID   int `gorm:"not null"`
+	FriendID int `gorm:"not null"`
+}
+
+func (v1FriendRelationship) TableName() string { return "friend_relationships" }
+
+type v1Blessing struct {
+	ID         int    `gorm:"primary_key"`
+	SenderID   int    `gorm:"not null"`
+	ReceiverID *int   `gorm:"default:null"`
+	Content    string `gorm:"not null"`
+	Font       string `gorm:"not null"`
+	PaperStyle string `gorm:"not null"`
+	CreatedAt  time.Time
+}
+
+func (v1Blessing) TableName() string { return "blessings" }
+
+type v1ChatMessage struct {
+	ID         int `gorm:"primaryKey"`
+	SenderID   int `gorm:"index"`
+	ReceiverID int `gorm:"index"`
+	Content    string
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+func (v1ChatMessage) TableName() string { return "chat_messages" }
+
+type v1Avatar struct {
+	gorm.Model
+	UserId   int    `gorm:"not null"`
+	Filename string `gorm:"unique"`
+	URL      string
+}
+
+func (v1Avatar) TableName() string { return "avatars" }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 每页默认条数和最大条数
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+var ErrInvalidCursor = newAppError("INVALID_CURSOR", http.StatusBadRequest, "分页游标无效", "Invalid pagination cursor")
+
+// 游标分页参数，cursor 为上一页响应中的 nextCursor，第一页不传
+type PageQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// 解析后的分页参数。列表按 (created_at, id) 倒序排列，after 为上一页最后一条记录的位置
+type page struct {
+	limit int
+	after *pageCursor
+}
+
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// 游标对客户端不透明：base64url(创建时间|ID)
+func encodeCursor(createdAt time.Time, id int) string {
+	raw := createdAt.Format(time.RFC3339Nano) + "|" + strconv.Itoa(id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ts, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &pageCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// 绑定并解析查询参数中的分页参数
+func bindPage(c *gin.Context) (page, error) {
+	var q PageQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		return page{}, invalidRequest(err)
+	}
+	p := page{limit: q.Limit}
+	if p.limit == 0 {
+		p.limit = defaultPageLimit
+	}
+	if q.Cursor != "" {
+		after, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return page{}, err
+		}
+		p.after = after
+	}
+	return p, nil
+}
+
+// 在查询上应用排序和游标条件，createdAt 和 id 为带表名的列名。多取一条用于判断是否还有下一页
+func (p page) apply(db *gorm.DB, createdAt, id string) *gorm.DB {
+	if p.after != nil {
+		db = db.Where("("+createdAt+" < ? OR ("+createdAt+" = ? AND "+id+" < ?))",
+			p.after.CreatedAt, p.after.CreatedAt, p.after.ID)
+	}
+	return db.Order(createdAt + " DESC").Order(id + " DESC").Limit(p.limit + 1)
+}
+
+// 去掉多取的一条，返回本页数据和下一页游标，没有下一页时游标为空
+func pageResult[T any](items []T, p page, key func(T) (time.Time, int)) ([]T, string) {
+	if len(items) <= p.limit {
+		return items, ""
+	}
+	items = items[:p.limit]
+	createdAt, id := key(items[len(items)-1])
+	return items, encodeCursor(createdAt, id)
+}
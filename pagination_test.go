@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPostPagination(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	_, bob := ts.signup("bob")
+
+	ids := make([]int, 5)
+	for i := range ids {
+		ids[i] = ts.createPost(alice, "说说 "+strconv.Itoa(i))
+	}
+	// 两条说说的发布时间相同时按 ID 排序
+	same := time.Now().Add(-time.Hour)
+	ts.db.Model(&Post{}).Where("id IN ?", []int{ids[1], ids[2]}).UpdateColumn("created_at", same)
+	want := []int{ids[4], ids[3], ids[0], ids[2], ids[1]}
+
+	var got []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("分页没有结束")
+		}
+		page := ts.listPosts(bob, "/posts?limit=2&cursor="+cursor)
+		if len(page.Posts) > 2 {
+			t.Fatalf("每页不应超过 2 条: %d", len(page.Posts))
+		}
+		for _, p := range page.Posts {
+			got = append(got, p.ID)
+		}
+		if pages == 0 {
+			// 翻页过程中发布的新说说不影响后续页
+			ts.createPost(alice, "新的说说")
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(got) != len(want) {
+		t.Fatalf("分页结果不符: %v，期望 %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("分页结果不符: %v，期望 %v", got, want)
+		}
+	}
+
+	if page := ts.listPosts(bob, "/posts"); len(page.Posts) != 6 || page.NextCursor != "" {
+		t.Fatalf("默认每页 20 条: %d next=%q", len(page.Posts), page.NextCursor)
+	}
+	expectError(t, ts.do(http.MethodGet, "/posts?cursor=not-a-cursor", bob, nil), "INVALID_CURSOR")
+	expectError(t, ts.do(http.MethodGet, "/posts?cursor="+encodeCursor(time.Now(), 1)[2:], bob, nil), "INVALID_CURSOR")
+	expectError(t, ts.do(http.MethodGet, "/posts?limit=101", bob, nil), "INVALID_REQUEST")
+
+	// 点赞列表使用相同的分页方式
+	for _, id := range ids[:3] {
+		expectOK(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(id)+"/like", bob, nil))
+	}
+	first := ts.listPosts(bob, "/posts/liked?limit=2")
+	if len(first.Posts) != 2 || first.NextCursor == "" || first.Posts[0].ID != ids[0] {
+		t.Fatalf("第一页点赞列表不符: %+v", first)
+	}
+	second := ts.listPosts(bob, "/posts/liked?limit=2&cursor="+first.NextCursor)
+	if len(second.Posts) != 1 || second.NextCursor != "" || second.Posts[0].ID != ids[1] || !second.Posts[0].IsLiked {
+		t.Fatalf("第二页点赞列表不符: %+v", second)
+	}
+}
+
+func TestCommentAndBlessingPagination(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	ts.befriend(alice, bob, bobID)
+
+	postPath := "/posts/" + strconv.Itoa(ts.createPost(alice, "新年快乐")) + "/comments"
+	for i := 0; i < 3; i++ {
+		expectOK(t, ts.do(http.MethodPost, postPath, bob, gin.H{"content": "评论 " + strconv.Itoa(i)}))
+	}
+	var comments struct {
+		Comments   []CommentView `json:"comments"`
+		NextCursor string        `json:"nextCursor"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, postPath+"?limit=2", alice, nil), &comments)
+	if len(comments.Comments) != 2 || comments.Comments[0].Content != "评论 2" || comments.NextCursor == "" {
+		t.Fatalf("第一页评论不符: %+v", comments)
+	}
+	mustDecode(t, ts.do(http.MethodGet, postPath+"?limit=2&cursor="+comments.NextCursor, alice, nil), &comments)
+	if len(comments.Comments) != 1 || comments.Comments[0].Content != "评论 0" || comments.NextCursor != "" {
+		t.Fatalf("第二页评论不符: %+v", comments)
+	}
+
+	for i := 0; i < 3; i++ {
+		expectOK(t, ts.do(http.MethodPost, "/blessings", alice, gin.H{"receiver_id": bobID, "content": "祝福 " + strconv.Itoa(i), "font": "kaiti", "paper_style": "red"}))
+		expectOK(t, ts.do(http.MethodPost, "/blessings", alice, gin.H{"content": "草稿 " + strconv.Itoa(i), "font": "kaiti", "paper_style": "red"}))
+	}
+	expectOK(t, ts.do(http.MethodPost, "/blessings", bob, gin.H{"receiver_id": aliceID, "content": "回礼", "font": "kaiti", "paper_style": "red"}))
+
+	type blessing struct {
+		Content string `json:"content"`
+	}
+	var sent struct {
+		Sent             []blessing `json:"sent_blessings"`
+		NextCursor       string     `json:"nextCursor"`
+		Drafts           []blessing `json:"草稿箱"`
+		DraftsNextCursor string     `json:"draftsNextCursor"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/blessings/sent?limit=2", alice, nil), &sent)
+	if len(sent.Sent) != 2 || sent.Sent[0].Content != "祝福 2" || sent.NextCursor == "" ||
+		len(sent.Drafts) != 2 || sent.Drafts[0].Content != "草稿 2" || sent.DraftsNextCursor == "" {
+		t.Fatalf("第一页发出的祝福不符: %+v", sent)
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/blessings/sent?limit=2&cursor="+sent.NextCursor+"&draftsCursor="+sent.DraftsNextCursor, alice, nil), &sent)
+	if len(sent.Sent) != 1 || sent.Sent[0].Content != "祝福 0" || sent.NextCursor != "" ||
+		len(sent.Drafts) != 1 || sent.Drafts[0].Content != "草稿 0" || sent.DraftsNextCursor != "" {
+		t.Fatalf("第二页发出的祝福不符: %+v", sent)
+	}
+	expectError(t, ts.do(http.MethodGet, "/blessings/sent?draftsCursor=bad", alice, nil), "INVALID_CURSOR")
+
+	var received struct {
+		Received   []blessing `json:"received_blessings"`
+		NextCursor string     `json:"nextCursor"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/blessings/received?limit=2", bob, nil), &received)
+	if len(received.Received) != 2 || received.Received[0].Content != "祝福 2" || received.NextCursor == "" {
+		t.Fatalf("第一页收到的祝福不符: %+v", received)
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/blessings/received?limit=2&cursor="+received.NextCursor, bob, nil), &received)
+	if len(received.Received) != 1 || received.Received[0].Content != "祝福 0" || received.NextCursor != "" {
+		t.Fatalf("第二页收到的祝福不符: %+v", received)
+	}
+}
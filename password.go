@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	resetKeyPrefix     = "password_reset:"      // 重置令牌哈希 -> 用户ID
+	userResetKeyPrefix = "password_reset_user:" // 用户ID -> 当前有效的重置令牌哈希
+)
+
+var (
+	ErrOldPasswordMismatch = newAppError("OLD_PASSWORD_MISMATCH", http.StatusBadRequest, "原密码不正确", "Current password is incorrect")
+	ErrResetTokenInvalid   = newAppError("RESET_TOKEN_INVALID", http.StatusBadRequest, "重置链接无效或已过期", "Invalid or expired reset token")
+)
+
+// 修改密码请求结构体
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// 找回密码请求结构体
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// 重置密码请求结构体
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// 密码重置令牌，一次性使用，保存在 Redis 中。每个用户同一时间只有最新签发的令牌有效
+type PasswordResets struct {
+	rdb *redis.Client
+	ttl time.Duration
+}
+
+func NewPasswordResets(rdb *redis.Client, ttl time.Duration) *PasswordResets {
+	return &PasswordResets{rdb: rdb, ttl: ttl}
+}
+
+// 为用户签发新的重置令牌，之前签发的令牌随即失效
+func (r *PasswordResets) Issue(ctx context.Context, userID int) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := r.Revoke(ctx, userID); err != nil {
+		return "", err
+	}
+	hash := hashToken(token)
+	_, err = r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, resetKeyPrefix+hash, userID, r.ttl)
+		p.Set(ctx, userResetKeyPrefix+strconv.Itoa(userID), hash, r.ttl)
+		return nil
+	})
+	return token, err
+}
+
+// 查询重置令牌对应的用户ID，不作废令牌
+func (r *PasswordResets) Lookup(ctx context.Context, token string) (int, error) {
+	userID, err := r.rdb.Get(ctx, resetKeyPrefix+hashToken(token)).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrResetTokenInvalid
+	}
+	return userID, err
+}
+
+// 使用重置令牌，返回对应的用户ID，令牌只能使用一次
+func (r *PasswordResets) Consume(ctx context.Context, token string) (int, error) {
+	userID, err := r.rdb.GetDel(ctx, resetKeyPrefix+hashToken(token)).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, r.rdb.Del(ctx, userResetKeyPrefix+strconv.Itoa(userID)).Err()
+}
+
+// 作废用户尚未使用的重置令牌
+func (r *PasswordResets) Revoke(ctx context.Context, userID int) error {
+	userKey := userResetKeyPrefix + strconv.Itoa(userID)
+	hash, err := r.rdb.GetDel(ctx, userKey).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return r.rdb.Del(ctx, resetKeyPrefix+hash).Err()
+}
+
+// 密码变更后吊销所有登录会话和未使用的重置令牌
+func (a *App) passwordChanged(ctx context.Context, userID int) error {
+	if err := a.auth.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+	return a.resets.Revoke(ctx, userID)
+}
+
+// 修改密码，成功后其他设备需要重新登录，当前设备获得新的令牌
+func (a *App) changePasswordHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	user, err := a.users.Get(userID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	if !checkPassword(req.OldPassword, user.Password) {
+		ResponseError(c, ErrOldPasswordMismatch)
+		return
+	}
+	if err := a.users.SetPassword(userID, req.NewPassword); err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if err := a.passwordChanged(ctx, userID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	tokens, err := a.auth.Login(ctx, userID, requestMeta(c))
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, tokens, "密码修改成功")
+}
+
+// 申请找回密码。无论邮箱是否存在都返回成功，避免泄露注册信息
+func (a *App) forgotPasswordHandler(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	const msg = "如果该邮箱已绑定账户，我们已发送重置密码的邮件"
+	user, err := a.users.FindByEmail(req.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		ResponseOK(c, nil, msg)
+		return
+	}
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := a.resets.Issue(ctx, user.ID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	mail := Mail{
+		To:      *user.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s，你好：\n\n请在 %d 分钟内打开以下链接重置密码：\n%s%s\n\n如果这不是你本人的操作，请忽略此邮件。\n",
+			user.UserName, int(a.cfg.Reset.TTL/time.Minute), a.cfg.Reset.URL, token),
+	}
+	if err := a.mailer.Send(ctx, mail); err != nil {
+		log.Printf("发送重置密码邮件失败: %v", err)
+	}
+	ResponseOK(c, nil, msg)
+}
+
+// 使用重置令牌设置新密码，所有已登录的设备都需要重新登录
+func (a *App) resetPasswordHandler(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, err := a.resets.Lookup(ctx, req.Token)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	// 先检查密码策略再作废令牌，新密码不符合策略时可以用同一令牌重试
+	if err := a.users.CheckNewPassword(userID, req.NewPassword); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	// 原子地作废令牌，并发请求中只有一个能够使用
+	if userID, err = a.resets.Consume(ctx, req.Token); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	if err := a.users.SetPassword(userID, req.NewPassword); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	if err := a.passwordChanged(ctx, userID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "密码已重置，请重新登录")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 记录发出的邮件，供测试检查
+type recordingMailer struct {
+	mu    sync.Mutex
+	mails []Mail
+}
+
+func (m *recordingMailer) Send(ctx context.Context, mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+func (m *recordingMailer) sent() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.mails...)
+}
+
+// 从重置邮件中取出令牌
+func (ts *testServer) resetToken(mail Mail) string {
+	ts.t.Helper()
+	i := strings.Index(mail.Body, ts.cfg.Reset.URL)
+	if i < 0 {
+		ts.t.Fatalf("邮件中没有重置链接:\n%s", mail.Body)
+	}
+	return strings.Fields(mail.Body[i+len(ts.cfg.Reset.URL):])[0]
+}
+
+func TestChangePassword(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup("alice")
+	phone := ts.login("alice")
+	laptop := ts.login("alice")
+
+	expectError(t, ts.do(http.MethodPut, "/password", laptop.AccessToken, gin.H{"oldPassword": "wrong", "newPassword": "newpass456"}), "OLD_PASSWORD_MISMATCH")
+	expectError(t, ts.do(http.MethodPut, "/password", laptop.AccessToken, gin.H{"oldPassword": "password123", "newPassword": "short"}), "PASSWORD_TOO_WEAK")
+
+	var tokens TokenPair
+	mustDecode(t, ts.do(http.MethodPut, "/password", laptop.AccessToken, gin.H{"oldPassword": "password123", "newPassword": "newpass456"}), &tokens)
+	expectOK(t, ts.do(http.MethodGet, "/posts", tokens.AccessToken, nil))
+
+	// 修改前签发的令牌全部失效
+	for _, old := range []TokenPair{phone, laptop} {
+		expectError(t, ts.do(http.MethodGet, "/posts", old.AccessToken, nil), "TOKEN_REVOKED")
+		expectError(t, ts.refresh(old.RefreshToken), "REFRESH_TOKEN_INVALID")
+	}
+	expectError(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "alice", "password": "password123"}), "INVALID_CREDENTIALS")
+	expectOK(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "alice", "password": "newpass456"}))
+}
+
+func TestPasswordReset(t *testing.T) {
+	ts := newTestServer(t)
+	mailer := &recordingMailer{}
+	ts.app.mailer = mailer
+
+	expectOK(t, ts.do(http.MethodPost, "/register", "", gin.H{"userName": "alice", "password": "password123", "email": "Alice@Example.com"}))
+	expectError(t, ts.do(http.MethodPost, "/register", "", gin.H{"userName": "bob", "password": "password123", "email": "alice@example.com"}), "EMAIL_TAKEN")
+	expectError(t, ts.do(http.MethodPost, "/register", "", gin.H{"userName": "bob", "password": "password123", "email": "not-an-email"}), "INVALID_REQUEST")
+	session := ts.login("alice")
+
+	// 未绑定的邮箱同样返回成功，但不发送邮件
+	expectOK(t, ts.do(http.MethodPost, "/password/forgot", "", gin.H{"email": "nobody@example.com"}))
+	if n := len(mailer.sent()); n != 0 {
+		t.Fatalf("不应发送邮件，实际 %d 封", n)
+	}
+
+	// 只有最新的重置令牌有效
+	expectOK(t, ts.do(http.MethodPost, "/password/forgot", "", gin.H{"email": "alice@example.com"}))
+	expectOK(t, ts.do(http.MethodPost, "/password/forgot", "", gin.H{"email": "ALICE@example.com"}))
+	mails := mailer.sent()
+	if len(mails) != 2 || mails[1].To != "alice@example.com" {
+		t.Fatalf("重置邮件不符: %+v", mails)
+	}
+	stale, token := ts.resetToken(mails[0]), ts.resetToken(mails[1])
+	expectError(t, ts.do(http.MethodPost, "/password/reset", "", gin.H{"token": stale, "newPassword": "newpass456"}), "RESET_TOKEN_INVALID")
+
+	// 新密码不符合策略时令牌仍然有效
+	expectError(t, ts.do(http.MethodPost, "/password/reset", "", gin.H{"token": token, "newPassword": "short"}), "PASSWORD_TOO_WEAK")
+	expectOK(t, ts.do(http.MethodPost, "/password/reset", "", gin.H{"token": token, "newPassword": "newpass456"}))
+	expectError(t, ts.do(http.MethodPost, "/password/reset", "", gin.H{"token": token, "newPassword": "again789"}), "RESET_TOKEN_INVALID")
+	expectError(t, ts.do(http.MethodGet, "/posts", session.AccessToken, nil), "TOKEN_REVOKED")
+	expectOK(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "alice", "password": "newpass456"}))
+
+	// 同时提交同一个令牌时只有一个请求成功
+	expectOK(t, ts.do(http.MethodPost, "/password/forgot", "", gin.H{"email": "alice@example.com"}))
+	token = ts.resetToken(mailer.sent()[2])
+	const n = 4
+	results := make(chan apiResponse, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			results <- ts.do(http.MethodPost, "/password/reset", "", gin.H{"token": token, "newPassword": "concurrent" + strconv.Itoa(i)})
+		}(i)
+	}
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		if resp := <-results; resp.Code == http.StatusOK {
+			succeeded++
+		} else if resp.Error == nil || resp.Error.Code != "RESET_TOKEN_INVALID" {
+			t.Fatalf("期望 RESET_TOKEN_INVALID，实际 %+v", resp)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("同一令牌应只能使用一次，实际成功 %d 次", succeeded)
+	}
+}
+
+func TestResetTokenExpires(t *testing.T) {
+	ts := newTestServer(t)
+	mailer := &recordingMailer{}
+	ts.app.mailer = mailer
+
+	_, token := ts.signup("alice")
+	expectOK(t, ts.do(http.MethodPut, "/profile", token, gin.H{"email": "alice@example.com"}))
+	expectOK(t, ts.do(http.MethodPost, "/password/forgot", "", gin.H{"email": "alice@example.com"}))
+
+	ts.redis.FastForward(ts.cfg.Reset.TTL)
+	expectError(t, ts.do(http.MethodPost, "/password/reset", "", gin.H{"token": ts.resetToken(mailer.sent()[0]), "newPassword": "newpass456"}), "RESET_TOKEN_INVALID")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcrypt 只使用密码的前 72 个字节
+const bcryptMaxBytes = 72
+
+var (
+	ErrUserNameInvalid = newAppError("USER_NAME_INVALID", http.StatusBadRequest, "用户名不符合要求", "User name does not meet the requirements")
+	ErrPasswordWeak    = newAppError("PASSWORD_TOO_WEAK", http.StatusBadRequest, "密码强度不足", "Password is too weak")
+)
+
+// 用户名和密码策略
+type Policy struct {
+	cfg      PolicyConfig
+	reserved map[string]struct{}
+	breached map[string]struct{}
+}
+
+// 创建策略，配置了泄露密码列表时从文件加载，每行一个密码，忽略空行和 # 开头的注释
+func NewPolicy(cfg PolicyConfig) (*Policy, error) {
+	p := &Policy{
+		cfg:      cfg,
+		reserved: make(map[string]struct{}, len(cfg.UserName.Reserved)),
+		breached: make(map[string]struct{}),
+	}
+	for _, name := range cfg.UserName.Reserved {
+		p.reserved[strings.ToLower(name)] = struct{}{}
+	}
+
+	if cfg.Password.BreachedFile == "" {
+		return p, nil
+	}
+	f, err := os.Open(cfg.Password.BreachedFile)
+	if err != nil {
+		return nil, fmt.Errorf("读取泄露密码列表失败: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取泄露密码列表失败: %w", err)
+	}
+	return p, nil
+}
+
+// 用户名允许的字符：字母（含中文等文字）、数字、下划线、点和连字符
+func validUserNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-'
+}
+
+// 校验用户名，不符合时返回带字段详情的 ErrUserNameInvalid
+func (p *Policy) CheckUserName(name string) error {
+	var details []FieldError
+	if n := utf8.RuneCountInString(name); n < p.cfg.UserName.MinLength || n > p.cfg.UserName.MaxLength {
+		details = append(details, FieldError{Field: "userName", Reason: "length"})
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return !validUserNameRune(r) }) >= 0 {
+		details = append(details, FieldError{Field: "userName", Reason: "charset"})
+	}
+	if _, ok := p.reserved[strings.ToLower(name)]; ok {
+		details = append(details, FieldError{Field: "userName", Reason: "reserved"})
+	}
+	if len(details) > 0 {
+		return ErrUserNameInvalid.WithDetails(details...)
+	}
+	return nil
+}
+
+// 校验密码，field 为返回详情中的字段名
+func (p *Policy) CheckPassword(field, password, userName string) error {
+	var details []FieldError
+	if utf8.RuneCountInString(password) < p.cfg.Password.MinLength {
+		details = append(details, FieldError{Field: field, Reason: "min_length"})
+	}
+	if len(password) > bcryptMaxBytes {
+		details = append(details, FieldError{Field: field, Reason: "max_length"})
+	}
+	if passwordClasses(password) < p.cfg.Password.MinClasses {
+		details = append(details, FieldError{Field: field, Reason: "classes"})
+	}
+	if userName != "" && strings.EqualFold(password, userName) {
+		details = append(details, FieldError{Field: field, Reason: "same_as_user_name"})
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		details = append(details, FieldError{Field: field, Reason: "breached"})
+	}
+	if len(details) > 0 {
+		return ErrPasswordWeak.WithDetails(details...)
+	}
+	return nil
+}
+
+// 密码包含的字符类别数：小写字母、大写字母、数字、其他符号
+func passwordClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPolicyUserName(t *testing.T) {
+	p, err := NewPolicy(DefaultConfig().Policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"alice", "小明_2024", "bob.smith-jr"} {
+		if err := p.CheckUserName(name); err != nil {
+			t.Errorf("%q 应合法: %v", name, err)
+		}
+	}
+	cases := map[string]string{
+		"ab":                                 "length",
+		"alice smith":                        "charset",
+		"party🎉":                             "charset",
+		"Admin":                              "reserved",
+		"a234567890123456789012345678901234": "length",
+	}
+	for name, reason := range cases {
+		err := p.CheckUserName(name)
+		var appErr *AppError
+		if !errors.As(err, &appErr) || appErr.Code != ErrUserNameInvalid.Code {
+			t.Fatalf("%q 应不合法，实际 %v", name, err)
+		}
+		if appErr.Details[0].Reason != reason {
+			t.Errorf("%q 的原因应为 %s，实际 %+v", name, reason, appErr.Details)
+		}
+	}
+}
+
+func TestPolicyPassword(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "breached.txt")
+	os.WriteFile(file, []byte("# 常见密码\nPassword1\n\nqwerty123\n"), 0o600)
+	cfg := DefaultConfig().Policy
+	cfg.Password.BreachedFile = file
+	p, err := NewPolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.CheckPassword("password", "correct-horse", "alice"); err != nil {
+		t.Fatalf("强密码应通过: %v", err)
+	}
+	cases := map[string]string{
+		"a1b2":      "min_length",
+		"abcdefghi": "classes",
+		"QWERTY123": "breached",
+	}
+	for password, reason := range cases {
+		err := p.CheckPassword("password", password, "alice")
+		var appErr *AppError
+		if !errors.As(err, &appErr) || appErr.Code != ErrPasswordWeak.Code {
+			t.Fatalf("%q 应被拒绝，实际 %v", password, err)
+		}
+		if appErr.Details[0] != (FieldError{Field: "password", Reason: reason}) {
+			t.Errorf("%q 的原因应为 %s，实际 %+v", password, reason, appErr.Details)
+		}
+	}
+	if err := p.CheckPassword("password", "Alice123", "alice123"); err == nil {
+		t.Fatal("与用户名相同的密码应被拒绝")
+	}
+
+	cfg.Password.BreachedFile = filepath.Join(t.TempDir(), "missing.txt")
+	if _, err := NewPolicy(cfg); err == nil {
+		t.Fatal("列表文件不存在时应返回错误")
+	}
+}
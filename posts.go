@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"net/http"
 	"strconv"
 	"time"
@@ -10,12 +12,37 @@ import (
 
 // 说说模型
 type Post struct {
+	ID        int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int    `gorm:"not null" json:"userId"`
+	Content   string `gorm:"type:text;not null" json:"content"`
+	LikeCount int    `gorm:"default:0" json:"likeCount"`
+	// 可见范围：public、friends、private 或 custom
+	Visibility string     `gorm:"type:varchar(10);not null;default:public" json:"visibility"`
+	EditedAt   *time.Time `json:"editedAt"` // 最后一次修改内容的时间，未修改过为 null
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+	// 删除的说说及其评论和点赞对所有人隐藏，注销账户清除数据时才真正删除
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	// 可见范围为 custom 时能看到的好友，只返回给作者本人
+	AudienceIDs []int `gorm:"-" json:"audienceIds,omitempty"`
+}
+
+// 说说除了个人信息的三种可见范围外，还可以只给指定的好友看
+const VisibilityCustom = "custom"
+
+// 可见范围为 custom 的说说能被哪些用户看到
+type PostAudience struct {
+	PostID int `gorm:"primaryKey;autoIncrement:false"`
+	UserID int `gorm:"primaryKey;autoIncrement:false;index"`
+}
+
+// 说说的修改记录，Content 为修改前的内容
+type PostEdit struct {
 	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID    int       `gorm:"not null" json:"userId"`
+	PostID    int       `gorm:"not null;index" json:"postId"`
+	EditorID  int       `gorm:"not null" json:"editorId"` // 作者本人或管理员
 	Content   string    `gorm:"type:text;not null" json:"content"`
-	LikeCount int       `gorm:"default:0" json:"likeCount"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"` // 修改时间
 }
 
 type PostView struct {
@@ -27,172 +54,576 @@ type PostView struct {
 // 点赞模型
 type Like struct {
 	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
-	PostID    int       `gorm:"not null" json:"postId"`
-	UserID    int       `gorm:"not null" json:"userId"`
+	PostID    int       `gorm:"not null;uniqueIndex:idx_likes_post_user" json:"postId"`
+	UserID    int       `gorm:"not null;uniqueIndex:idx_likes_post_user" json:"userId"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"createdAt"`
 }
 
 // 发布说说请求结构体
 type CreatePostRequest struct {
+	Content     string `json:"content" binding:"required"`
+	Visibility  string `json:"visibility" binding:"omitempty,oneof=public friends private custom"` // 默认 public
+	AudienceIDs []int  `json:"audienceIds" binding:"omitempty,max=100"`                            // visibility 为 custom 时必填，只能是好友
+}
+
+// 修改说说请求结构体
+type UpdatePostRequest struct {
 	Content string `json:"content" binding:"required"`
 }
 
-// 发布说说
-func createPostHandler(c *gin.Context, db *gorm.DB) {
-	userID := c.MustGet("userID").(int)
+var (
+	ErrAlreadyLiked  = newAppError("ALREADY_LIKED", http.StatusConflict, "已经点赞", "Already liked")
+	ErrNotLiked      = newAppError("NOT_LIKED", http.StatusConflict, "不存在点赞记录，无法取消点赞", "Not liked yet")
+	ErrInvalidPostID = newAppError("INVALID_POST_ID", http.StatusBadRequest, "帖子ID无效", "Invalid post id")
+	ErrPostNotFound  = newAppError("POST_NOT_FOUND", http.StatusNotFound, "说说不存在", "Post not found")
+)
 
-	var req CreatePostRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, err.Error())
-		return
-	}
+// 说说领域服务
+type PostService struct {
+	db   *gorm.DB
+	load *Loader
+}
+
+func NewPostService(db *gorm.DB) *PostService {
+	return &PostService{db: db, load: NewLoader(db)}
+}
 
+func (s *PostService) Create(userID int, req CreatePostRequest) (*Post, error) {
 	post := Post{
-		UserID:  userID,
-		Content: req.Content,
+		UserID:     userID,
+		Content:    req.Content,
+		Visibility: req.Visibility,
+	}
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+	audience, err := s.checkAudience(userID, post.Visibility, req.AudienceIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := db.Create(&post).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, err.Error())
-		return
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		rows := make([]PostAudience, len(audience))
+		for i, id := range audience {
+			rows[i] = PostAudience{PostID: post.ID, UserID: id}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
 	}
+	post.AudienceIDs = audience
+	return &post, nil
+}
 
-	ResponseOK(c, gin.H{
-		"id":        post.ID,
-		"content":   post.Content,
-		"createdAt": post.CreatedAt,
-	}, "发布成功")
+// 校验指定的好友名单，返回去重后的用户ID
+func (s *PostService) checkAudience(userID int, visibility string, ids []int) ([]int, error) {
+	if visibility != VisibilityCustom {
+		if len(ids) > 0 {
+			return nil, ErrInvalidRequest.WithDetails(FieldError{Field: "audienceIds", Reason: "excluded"})
+		}
+		return nil, nil
+	}
+	seen := make(map[int]bool, len(ids))
+	audience := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			audience = append(audience, id)
+		}
+	}
+	if len(audience) == 0 {
+		return nil, ErrInvalidRequest.WithDetails(FieldError{Field: "audienceIds", Reason: "required"})
+	}
+	var friends int64
+	if err := s.db.Model(&FriendRelationship{}).Where("user_id = ? AND friend_id IN ?", userID, audience).Count(&friends).Error; err != nil {
+		return nil, err
+	}
+	if int(friends) != len(audience) {
+		return nil, ErrInvalidRequest.WithDetails(FieldError{Field: "audienceIds", Reason: "not_friend"})
+	}
+	return audience, nil
 }
 
-// 查询说说
-func getPostsHandler(c *gin.Context, db *gorm.DB) {
-	userID := c.MustGet("userID").(int)
+// 查看者能看到的说说的查询条件：未删除，且是自己的、公开的、作者好友可见的，或指定给自己且仍是作者好友的
+func postVisibleTo(db *gorm.DB, viewerID int) *gorm.DB {
+	authors := db.Model(&FriendRelationship{}).Select("user_id").Where("friend_id = ?", viewerID)
+	audience := db.Model(&PostAudience{}).Select("post_id").Where("user_id = ?", viewerID)
+	return db.Where("posts.deleted_at IS NULL").Where(
+		db.Where("posts.user_id = ? OR posts.visibility = ?", viewerID, VisibilityPublic).
+			Or("posts.visibility = ? AND posts.user_id IN (?)", VisibilityFriends, authors).
+			Or("posts.visibility = ? AND posts.user_id IN (?) AND posts.id IN (?)", VisibilityCustom, authors, audience),
+	)
+}
+
+// 检查说说存在且对查看者可见。看不到的说说与不存在一样返回 ErrPostNotFound
+func checkPostVisible(db *gorm.DB, viewerID, postID int) error {
+	var count int64
+	if err := db.Model(&Post{}).Where("posts.id = ?", postID).Where(postVisibleTo(db, viewerID)).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+// 发现：所有用户的说说
+func (s *PostService) Discover(viewerID int, p page) ([]PostView, string, error) {
+	return s.list(viewerID, p, s.db)
+}
 
-	var posts []PostView
-	if err := db.Table("posts").
+// 动态：当前用户和好友的说说
+func (s *PostService) Feed(viewerID int, p page) ([]PostView, string, error) {
+	friendIDs := s.db.Model(&FriendRelationship{}).Select("friend_id").Where("user_id = ?", viewerID)
+	return s.list(viewerID, p, s.db.Where("posts.user_id = ? OR posts.user_id IN (?)", viewerID, friendIDs))
+}
+
+// 某个用户发布的说说
+func (s *PostService) ByAuthor(viewerID, authorID int, p page) ([]PostView, string, error) {
+	return s.list(viewerID, p, s.db.Where("posts.user_id = ?", authorID))
+}
+
+// 按时间倒序分页查询满足 scope 条件的说说，并标记当前用户是否已点赞。返回本页和下一页游标
+func (s *PostService) list(viewerID int, p page, scope *gorm.DB) ([]PostView, string, error) {
+	posts := []PostView{}
+	query := s.db.Table("posts").
 		Select("posts.*, users.nick_name").
 		Joins("LEFT JOIN users ON posts.user_id = users.id").
-		Order("posts.created_at DESC").
-		Find(&posts).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, err.Error())
-		return
+		Where("users.deleted_at IS NULL"). // 不显示已注销用户的说说
+		Where(postVisibleTo(s.db, viewerID)).
+		Where(scope)
+	if err := p.apply(query, "posts.created_at", "posts.id").Find(&posts).Error; err != nil {
+		return nil, "", err
 	}
+	posts, next := pageResult(posts, p, postViewKey)
 
-	// 检查当前用户是否已经点赞
+	// 一次查出当前用户点赞过本页中的哪些说说
+	ids := make([]int, len(posts))
 	for i := range posts {
-		var like Like
-		if err := db.Where("post_id = ? AND user_id = ?", posts[i].ID, userID).First(&like).Error; err == nil {
-			posts[i].IsLiked = true
-		} else {
-			posts[i].IsLiked = false
+		ids[i] = posts[i].ID
+	}
+	liked, err := s.load.LikedPosts(viewerID, ids)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range posts {
+		posts[i].IsLiked = liked[posts[i].ID]
+	}
+	if err := s.fillAudiences(viewerID, postViewsOf(posts)); err != nil {
+		return nil, "", err
+	}
+	return posts, next, nil
+}
+
+// 为作者本人填充 custom 说说指定的好友，其他人看到的说说不带这一字段
+func (s *PostService) fillAudiences(viewerID int, posts []*Post) error {
+	var ids []int
+	for _, p := range posts {
+		if p.UserID == viewerID && p.Visibility == VisibilityCustom {
+			ids = append(ids, p.ID)
 		}
 	}
+	if len(ids) == 0 {
+		return nil
+	}
+	audiences, err := s.load.PostAudiences(ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range posts {
+		if p.UserID == viewerID {
+			p.AudienceIDs = audiences[p.ID]
+		}
+	}
+	return nil
+}
 
-	ResponseOK(c, posts, "查询成功")
+func postViewsOf(views []PostView) []*Post {
+	posts := make([]*Post, len(views))
+	for i := range views {
+		posts[i] = &views[i].Post
+	}
+	return posts
 }
 
-// 点赞说说
-func likePostHandler(c *gin.Context, db *gorm.DB) {
-	userID := c.MustGet("userID").(int)
-	postIDStr := c.Param("id")
+func postViewKey(p PostView) (time.Time, int) {
+	return p.CreatedAt, p.ID
+}
 
-	postID, err := strconv.Atoi(postIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "post id is invalid",
-		})
-		return
+func (s *PostService) Like(userID, postID int) (*Like, error) {
+	if err := checkPostVisible(s.db, userID, postID); err != nil {
+		return nil, err
 	}
-
 	// 检查是否已经点赞
 	var like Like
-	if err := db.Where("post_id = ? AND user_id = ?", postID, userID).First(&like).Error; err == nil {
-		ResponseFAIL(c, 500, "已经点赞")
-		return
+	if err := s.db.Where("post_id = ? AND user_id = ?", postID, userID).First(&like).Error; err == nil {
+		return nil, ErrAlreadyLiked
 	}
 
-	// 创建点赞记录
 	like = Like{
 		PostID: postID,
 		UserID: userID,
 	}
-	if err := db.Create(&like).Error; err != nil {
-		ResponseFAIL(c, 500, err.Error())
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 创建点赞记录
+		if err := tx.Create(&like).Error; err != nil {
+			return err
+		}
+		// 更新点赞数
+		return tx.Model(&Post{}).Where("id = ?", postID).Update("like_count", gorm.Expr("like_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &like, nil
+}
+
+// 删除说说（软删除），其下的评论和点赞随之隐藏
+func (s *PostService) Delete(postID int) error {
+	return deletePost(s.db, postID)
+}
+
+func deletePost(tx *gorm.DB, postID int) error {
+	res := tx.Delete(&Post{}, postID)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+// 作者本人和管理员可以修改、删除说说。非管理员看不到的说说返回 ErrPostNotFound。
+// 在事务中调用时锁定该说说，直到事务结束
+func (s *PostService) editable(tx *gorm.DB, actorID int, admin bool, postID int) (*Post, error) {
+	if !admin {
+		if err := checkPostVisible(tx, actorID, postID); err != nil {
+			return nil, err
+		}
+	}
+	var post Post
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&post, postID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPostNotFound
+		}
+		return nil, err
+	}
+	if post.UserID != actorID && !admin {
+		return nil, ErrForbidden
+	}
+	return &post, nil
+}
+
+// 修改说说内容，并保存修改前的内容。内容没有变化时不记录。
+// 读取和写入在同一事务中且锁定该说说，并发修改时每次修改前的内容都会被记录
+func (s *PostService) Update(actorID int, admin bool, postID int, content string) (*Post, error) {
+	var post *Post
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		post, err = s.editable(tx, actorID, admin, postID)
+		if err != nil {
+			return err
+		}
+		if post.Content == content {
+			return nil
+		}
+
+		now := time.Now()
+		edit := PostEdit{PostID: post.ID, EditorID: actorID, Content: post.Content, CreatedAt: now}
+		if err := tx.Create(&edit).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(post).Updates(map[string]interface{}{"content": content, "edited_at": now}).Error; err != nil {
+			return err
+		}
+		post.Content = content
+		post.EditedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fillAudiences(actorID, []*Post{post}); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+// 作者本人或管理员删除说说。检查和删除在同一事务中，与修改互斥
+func (s *PostService) Remove(actorID int, admin bool, postID int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		post, err := s.editable(tx, actorID, admin, postID)
+		if err != nil {
+			return err
+		}
+		return deletePost(tx, post.ID)
+	})
+}
+
+// 说说的修改记录，最近的修改在前。管理员可以查看任何未删除的说说的修改记录
+func (s *PostService) History(viewerID int, admin bool, postID int) ([]PostEdit, error) {
+	if admin {
+		if err := s.db.Select("id").First(&Post{}, postID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrPostNotFound
+			}
+			return nil, err
+		}
+	} else if err := checkPostVisible(s.db, viewerID, postID); err != nil {
+		return nil, err
+	}
+	edits := []PostEdit{}
+	if err := s.db.Where("post_id = ?", postID).Order("id DESC").Find(&edits).Error; err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+func (s *PostService) Unlike(userID, postID int) error {
+	if err := checkPostVisible(s.db, userID, postID); err != nil {
+		return err
+	}
+	// 查询是否存在点赞
+	var like Like
+	if err := s.db.Where("post_id = ? AND user_id = ?", postID, userID).First(&like).Error; err != nil {
+		return ErrNotLiked
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		// 删除点赞记录
+		if err := tx.Where("post_id = ? AND user_id = ?", postID, userID).Delete(&Like{}).Error; err != nil {
+			return err
+		}
+		// 更新点赞数
+		return tx.Model(&Post{}).Where("id = ?", postID).Update("like_count", gorm.Expr("like_count - 1")).Error
+	})
+}
+
+// 分页查询某人已点赞的帖子，按说说发布时间倒序
+func (s *PostService) Liked(userID int, p page) ([]PostView, string, error) {
+	posts := []PostView{}
+	query := s.db.Table("posts").
+		Select("posts.*, users.nick_name").
+		Joins("JOIN likes ON likes.post_id = posts.id AND likes.user_id = ?", userID).
+		Joins("LEFT JOIN users ON posts.user_id = users.id").
+		Where("users.deleted_at IS NULL"). // 不显示已注销用户的说说
+		Where(postVisibleTo(s.db, userID)) // 点赞后不再可见的说说同样不显示
+	if err := p.apply(query, "posts.created_at", "posts.id").Find(&posts).Error; err != nil {
+		return nil, "", err
+	}
+	posts, next := pageResult(posts, p, postViewKey)
+
+	// 设置 IsLiked 字段为 true（因为这些帖子是用户已经点赞的）
+	for i := range posts {
+		posts[i].IsLiked = true
+	}
+	if err := s.fillAudiences(userID, postViewsOf(posts)); err != nil {
+		return nil, "", err
+	}
+	return posts, next, nil
+}
+
+// 发布说说
+func (a *App) createPostHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	var req CreatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
 		return
 	}
 
-	// 更新点赞数
-	if err := db.Model(&Post{}).Where("id = ?", postID).Update("like_count", gorm.Expr("like_count + 1")).Error; err != nil {
-		ResponseFAIL(c, 500, err.Error())
+	post, err := a.posts.Create(userID, req)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 
-	ResponseOK(c, like, "点赞成功")
+	resp := gin.H{
+		"id":         post.ID,
+		"content":    post.Content,
+		"visibility": post.Visibility,
+		"createdAt":  post.CreatedAt,
+	}
+	if post.AudienceIDs != nil {
+		resp["audienceIds"] = post.AudienceIDs
+	}
+	ResponseOK(c, resp, "发布成功")
 }
 
-// 取消点赞
-func unlikePostHandler(c *gin.Context, db *gorm.DB) {
+// 发现：查询所有用户的说说
+func (a *App) getPostsHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	p, err := bindPage(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	posts, next, err := a.posts.Discover(userID, p)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"posts": posts, "nextCursor": next}, "查询成功")
+}
+
+// 动态：查询自己和好友的说说
+func (a *App) getFeedHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	p, err := bindPage(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	posts, next, err := a.posts.Feed(userID, p)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"posts": posts, "nextCursor": next}, "查询成功")
+}
+
+// 查询某个用户发布的说说
+func (a *App) getUserPostsHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	authorID, err := userIDParam(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	p, err := bindPage(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	if _, err := a.users.Get(authorID); err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	posts, next, err := a.posts.ByAuthor(userID, authorID, p)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"posts": posts, "nextCursor": next}, "查询成功")
+}
+
+// 点赞说说
+func (a *App) likePostHandler(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
 	postIDStr := c.Param("id")
 
 	postID, err := strconv.Atoi(postIDStr)
 	if err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, err.Error())
+		ResponseError(c, ErrInvalidPostID)
 		return
 	}
-	// 查询是否存在点赞
-	var like Like
-	if err := db.Where("post_id = ? AND user_id = ?", postID, userID).First(&like).Error; err != nil {
-		ResponseFAIL(c, 500, "不存在点赞记录，无法取消点赞")
+
+	like, err := a.posts.Like(userID, postID)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
-	// 删除点赞记录
-	if err := db.Where("post_id = ? AND user_id = ?", postID, userID).Delete(&Like{}).Error; err != nil {
-		ResponseFAIL(c, 500, "取消点赞失败")
+	ResponseOK(c, like, "点赞成功")
+}
+
+// 取消点赞
+func (a *App) unlikePostHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	postIDStr := c.Param("id")
+
+	postID, err := strconv.Atoi(postIDStr)
+	if err != nil {
+		ResponseError(c, ErrInvalidPostID)
 		return
 	}
 
-	// 更新点赞数
-	if err := db.Model(&Post{}).Where("id = ?", postID).Update("like_count", gorm.Expr("like_count - 1")).Error; err != nil {
-		ResponseFAIL(c, 500, "更新点赞数失败")
+	if err := a.posts.Unlike(userID, postID); err != nil {
+		ResponseError(c, err)
 		return
 	}
-
 	ResponseOK(c, nil, "取消点赞成功")
 }
 
 // 查询某人已点赞的帖子
-func getLikedPostsHandler(c *gin.Context, db *gorm.DB) {
+func (a *App) getLikedPostsHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	p, err := bindPage(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+
+	posts, next, err := a.posts.Liked(userID, p)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"posts": posts, "nextCursor": next}, "查询成功")
+}
+
+// 修改说说，仅限作者本人和管理员
+func (a *App) updatePostHandler(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ResponseError(c, ErrInvalidPostID)
+		return
+	}
 
-	var likes []Like
-	if err := db.Where("user_id = ?", userID).Find(&likes).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, err.Error())
+	var req UpdatePostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
 		return
 	}
 
-	// 提取帖子ID
-	var postIDs []int
-	for _, like := range likes {
-		postIDs = append(postIDs, like.PostID)
+	post, err := a.posts.Update(userID, hasRole(c.GetString(roleKey), RoleAdmin), postID, req.Content)
+	if err != nil {
+		ResponseError(c, err)
+		return
 	}
+	ResponseOK(c, post, "修改成功")
+}
 
-	// 查询帖子详情
-	var posts []PostView
-	if err := db.Table("posts").
-		Select("posts.*, users.nick_name").
-		Where("posts.id IN ?", postIDs).
-		Joins("LEFT JOIN users ON posts.user_id = users.id").
-		Order("posts.created_at DESC").
-		Find(&posts).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, err.Error())
+// 删除说说，仅限作者本人和管理员
+func (a *App) deletePostHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ResponseError(c, ErrInvalidPostID)
 		return
 	}
 
-	// 设置 IsLiked 字段为 true（因为这些帖子是用户已经点赞的）
-	for i := range posts {
-		posts[i].IsLiked = true
+	if err := a.posts.Remove(userID, hasRole(c.GetString(roleKey), RoleAdmin), postID); err != nil {
+		ResponseError(c, err)
+		return
 	}
+	ResponseOK(c, nil, "说说已删除")
+}
 
-	ResponseOK(c, posts, "查询成功")
+// 查询说说的修改记录
+func (a *App) getPostHistoryHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ResponseError(c, ErrInvalidPostID)
+		return
+	}
+
+	edits, err := a.posts.History(userID, hasRole(c.GetString(roleKey), RoleAdmin), postID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"edits": edits}, "查询成功")
 }
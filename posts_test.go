@@ -0,0 +1,358 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 发布一条说说并返回其ID
+func (ts *testServer) createPost(token, content string) int {
+	ts.t.Helper()
+	var post struct {
+		ID int `json:"id"`
+	}
+	mustDecode(ts.t, ts.do(http.MethodPost, "/posts", token, gin.H{"content": content}), &post)
+	return post.ID
+}
+
+// 一页说说列表
+type postPage struct {
+	Posts      []PostView `json:"posts"`
+	NextCursor string     `json:"nextCursor"`
+}
+
+func (ts *testServer) listPosts(token, path string) postPage {
+	ts.t.Helper()
+	var page postPage
+	mustDecode(ts.t, ts.do(http.MethodGet, path, token, nil), &page)
+	return page
+}
+
+func TestPostsAndLikes(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	_, bob := ts.signup("bob")
+
+	first := ts.createPost(alice, "新年快乐")
+	second := ts.createPost(bob, "中秋快乐")
+	expectCode(t, ts.do(http.MethodPost, "/posts", alice, gin.H{}), http.StatusBadRequest)
+
+	expectOK(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(first)+"/like", bob, nil))
+	expectCode(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(first)+"/like", bob, nil), http.StatusConflict)
+	expectError(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(first)+"/like", bob, nil), "ALREADY_LIKED")
+	expectError(t, ts.do(http.MethodPost, "/posts/abc/like", bob, nil), "INVALID_POST_ID")
+
+	posts := ts.listPosts(bob, "/posts").Posts
+	if len(posts) != 2 {
+		t.Fatalf("期望 2 条说说，实际 %d", len(posts))
+	}
+	for _, p := range posts {
+		switch p.ID {
+		case first:
+			if !p.IsLiked || p.LikeCount != 1 {
+				t.Fatalf("说说 %d 应被点赞一次: %+v", p.ID, p)
+			}
+		case second:
+			if p.IsLiked || p.LikeCount != 0 {
+				t.Fatalf("说说 %d 不应被点赞: %+v", p.ID, p)
+			}
+		}
+	}
+
+	liked := ts.listPosts(bob, "/posts/liked").Posts
+	if len(liked) != 1 || liked[0].ID != first {
+		t.Fatalf("点赞列表不符: %+v", liked)
+	}
+
+	expectOK(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(first)+"/unlike", bob, nil))
+	expectCode(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(first)+"/unlike", bob, nil), http.StatusConflict)
+
+	liked = ts.listPosts(bob, "/posts/liked").Posts
+	if len(liked) != 0 {
+		t.Fatalf("取消点赞后列表应为空: %+v", liked)
+	}
+}
+
+func postIDs(posts []PostView) []int {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func expectPostIDs(t *testing.T, name string, got []PostView, want ...int) {
+	t.Helper()
+	ids := postIDs(got)
+	if len(ids) != len(want) {
+		t.Fatalf("%s: 说说不符: %v，期望 %v", name, ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("%s: 说说不符: %v，期望 %v", name, ids, want)
+		}
+	}
+}
+
+func TestFeedAndUserPosts(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	carolID, carol := ts.signup("carol")
+	ts.befriend(alice, bob, bobID)
+
+	a1 := ts.createPost(alice, "alice 1")
+	b1 := ts.createPost(bob, "bob 1")
+	c1 := ts.createPost(carol, "carol 1")
+	a2 := ts.createPost(alice, "alice 2")
+	expectOK(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(b1)+"/like", alice, nil))
+
+	feed := ts.listPosts(alice, "/feed").Posts
+	expectPostIDs(t, "alice 的动态", feed, a2, b1, a1)
+	if !feed[1].IsLiked || feed[0].IsLiked {
+		t.Fatalf("点赞状态不符: %+v", feed)
+	}
+	expectPostIDs(t, "bob 的动态", ts.listPosts(bob, "/feed").Posts, a2, b1, a1)
+	expectPostIDs(t, "carol 的动态", ts.listPosts(carol, "/feed").Posts, c1)
+	expectPostIDs(t, "发现", ts.listPosts(carol, "/posts/discover").Posts, a2, c1, b1, a1)
+	expectPostIDs(t, "兼容的发现", ts.listPosts(carol, "/posts").Posts, a2, c1, b1, a1)
+
+	// 动态同样支持游标分页
+	first := ts.listPosts(alice, "/feed?limit=2")
+	expectPostIDs(t, "动态第一页", first.Posts, a2, b1)
+	expectPostIDs(t, "动态第二页", ts.listPosts(alice, "/feed?limit=2&cursor="+first.NextCursor).Posts, a1)
+
+	expectPostIDs(t, "alice 的主页", ts.listPosts(carol, "/users/"+strconv.Itoa(aliceID)+"/posts").Posts, a2, a1)
+	expectPostIDs(t, "carol 的主页", ts.listPosts(alice, "/users/"+strconv.Itoa(carolID)+"/posts").Posts, c1)
+	expectError(t, ts.do(http.MethodGet, "/users/abc/posts", alice, nil), "INVALID_USER_ID")
+	expectError(t, ts.do(http.MethodGet, "/users/9999/posts", alice, nil), "USER_NOT_FOUND")
+	expectCode(t, ts.do(http.MethodGet, "/feed", "", nil), http.StatusUnauthorized)
+}
+
+func TestPostVisibility(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	carolID, carol := ts.signup("carol")
+	_, dave := ts.signup("dave")
+	ts.befriend(alice, bob, bobID)
+	ts.befriend(alice, carol, carolID)
+
+	var post struct {
+		ID          int    `json:"id"`
+		Visibility  string `json:"visibility"`
+		AudienceIDs []int  `json:"audienceIds"`
+	}
+	create := func(body gin.H, visibility string) int {
+		t.Helper()
+		post.AudienceIDs = nil
+		mustDecode(t, ts.do(http.MethodPost, "/posts", alice, body), &post)
+		if post.Visibility != visibility {
+			t.Fatalf("可见范围不符: %+v", post)
+		}
+		return post.ID
+	}
+	public := create(gin.H{"content": "公开"}, VisibilityPublic)
+	friends := create(gin.H{"content": "好友可见", "visibility": "friends"}, VisibilityFriends)
+	private := create(gin.H{"content": "仅自己", "visibility": "private"}, VisibilityPrivate)
+	custom := create(gin.H{"content": "给 bob", "visibility": "custom", "audienceIds": []int{bobID, bobID}}, VisibilityCustom)
+	if len(post.AudienceIDs) != 1 || post.AudienceIDs[0] != bobID {
+		t.Fatalf("指定的好友应去重: %+v", post.AudienceIDs)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		token   string
+		visible []int
+	}{
+		{"本人", alice, []int{custom, private, friends, public}},
+		{"指定的好友", bob, []int{custom, friends, public}},
+		{"其他好友", carol, []int{friends, public}},
+		{"陌生人", dave, []int{public}},
+	} {
+		expectPostIDs(t, tc.name+"的发现", ts.listPosts(tc.token, "/posts/discover").Posts, tc.visible...)
+		expectPostIDs(t, tc.name+"看到的主页", ts.listPosts(tc.token, "/users/"+strconv.Itoa(aliceID)+"/posts").Posts, tc.visible...)
+		if tc.token != dave {
+			expectPostIDs(t, tc.name+"的动态", ts.listPosts(tc.token, "/feed").Posts, tc.visible...)
+		}
+	}
+
+	// 指定的好友只返回给作者本人：列表和修改后的结果中都有，其他人看到的没有
+	audienceOf := func(posts []PostView, id int) []int {
+		t.Helper()
+		for _, p := range posts {
+			if p.ID == id {
+				return p.AudienceIDs
+			}
+		}
+		t.Fatalf("列表中没有说说 %d", id)
+		return nil
+	}
+	if got := audienceOf(ts.listPosts(alice, "/posts/discover").Posts, custom); len(got) != 1 || got[0] != bobID {
+		t.Fatalf("作者看到的指定好友不符: %v", got)
+	}
+	if got := audienceOf(ts.listPosts(bob, "/posts/discover").Posts, custom); got != nil {
+		t.Fatalf("指定的好友不应看到可见范围: %v", got)
+	}
+	post.AudienceIDs = nil
+	mustDecode(t, ts.do(http.MethodPut, "/posts/"+strconv.Itoa(custom), alice, gin.H{"content": "给 bob（修改）"}), &post)
+	if len(post.AudienceIDs) != 1 || post.AudienceIDs[0] != bobID {
+		t.Fatalf("修改后应返回指定的好友: %+v", post)
+	}
+
+	// 看不到的说说不能点赞、评论，也看不到评论
+	for _, id := range []int{friends, private, custom} {
+		path := "/posts/" + strconv.Itoa(id)
+		expectError(t, ts.do(http.MethodPost, path+"/like", dave, nil), "POST_NOT_FOUND")
+		expectError(t, ts.do(http.MethodPost, path+"/comments", dave, gin.H{"content": "路过"}), "POST_NOT_FOUND")
+		expectError(t, ts.do(http.MethodGet, path+"/comments", dave, nil), "POST_NOT_FOUND")
+	}
+	expectError(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(custom)+"/like", carol, nil), "POST_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPost, "/posts/9999/like", bob, nil), "POST_NOT_FOUND")
+
+	// 指定的好友可以点赞和评论；评论对看不到说说的人同样不可点赞
+	customPath := "/posts/" + strconv.Itoa(custom)
+	expectOK(t, ts.do(http.MethodPost, customPath+"/like", bob, nil))
+	var comment struct {
+		ID int `json:"id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, customPath+"/comments", bob, gin.H{"content": "谢谢"}), &comment)
+	expectError(t, ts.do(http.MethodPost, "/comments/"+strconv.Itoa(comment.ID)+"/like", carol, nil), "COMMENT_NOT_FOUND")
+	expectOK(t, ts.do(http.MethodPost, "/comments/"+strconv.Itoa(comment.ID)+"/like", alice, nil))
+	expectPostIDs(t, "bob 的点赞列表", ts.listPosts(bob, "/posts/liked").Posts, custom)
+
+	// 解除好友后不再能看到指定给自己的说说，点赞列表中也不再显示
+	ts.db.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", aliceID, bobID, bobID, aliceID).Delete(&FriendRelationship{})
+	expectPostIDs(t, "解除好友后的发现", ts.listPosts(bob, "/posts/discover").Posts, public)
+	expectPostIDs(t, "解除好友后的点赞列表", ts.listPosts(bob, "/posts/liked").Posts)
+	expectError(t, ts.do(http.MethodPost, customPath+"/unlike", bob, nil), "POST_NOT_FOUND")
+
+	for _, tc := range []struct {
+		body   gin.H
+		detail FieldError
+	}{
+		{gin.H{"content": "x", "visibility": "custom"}, FieldError{Field: "audienceIds", Reason: "required"}},
+		{gin.H{"content": "x", "visibility": "custom", "audienceIds": []int{bobID}}, FieldError{Field: "audienceIds", Reason: "not_friend"}},
+		{gin.H{"content": "x", "visibility": "friends", "audienceIds": []int{carolID}}, FieldError{Field: "audienceIds", Reason: "excluded"}},
+		{gin.H{"content": "x", "visibility": "family"}, FieldError{Field: "visibility", Reason: "oneof"}},
+	} {
+		resp := ts.do(http.MethodPost, "/posts", alice, tc.body)
+		expectError(t, resp, "INVALID_REQUEST")
+		if len(resp.Error.Details) != 1 || resp.Error.Details[0] != tc.detail {
+			t.Fatalf("%v: 字段详情不符: %+v", tc.body, resp.Error.Details)
+		}
+	}
+}
+
+func TestEditAndDeletePost(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	_, bob := ts.signup("bob")
+	_, admin := ts.signup("admin1")
+	ts.setRole("admin1", RoleAdmin)
+	_, mod := ts.signup("moderator1")
+	ts.setRole("moderator1", RoleModerator)
+
+	postID := ts.createPost(alice, "新年快乐")
+	path := "/posts/" + strconv.Itoa(postID)
+	if p := ts.listPosts(bob, "/posts").Posts; len(p) != 1 || p[0].EditedAt != nil {
+		t.Fatalf("未修改的说说 editedAt 应为空: %+v", p)
+	}
+
+	var post Post
+	mustDecode(t, ts.do(http.MethodPut, path, alice, gin.H{"content": "新年快乐！"}), &post)
+	if post.Content != "新年快乐！" || post.EditedAt == nil {
+		t.Fatalf("修改结果不符: %+v", post)
+	}
+	// 内容没有变化时不产生修改记录
+	expectOK(t, ts.do(http.MethodPut, path, alice, gin.H{"content": "新年快乐！"}))
+	expectOK(t, ts.do(http.MethodPut, path, admin, gin.H{"content": "新年快乐"}))
+
+	expectError(t, ts.do(http.MethodPut, path, bob, gin.H{"content": "改掉"}), "FORBIDDEN")
+	expectError(t, ts.do(http.MethodPut, path, mod, gin.H{"content": "改掉"}), "FORBIDDEN")
+	expectError(t, ts.do(http.MethodPut, path, alice, gin.H{}), "INVALID_REQUEST")
+	expectError(t, ts.do(http.MethodPut, "/posts/abc", alice, gin.H{"content": "x"}), "INVALID_POST_ID")
+	expectError(t, ts.do(http.MethodPut, "/posts/9999", alice, gin.H{"content": "x"}), "POST_NOT_FOUND")
+
+	list := ts.listPosts(bob, "/posts").Posts
+	if len(list) != 1 || list[0].Content != "新年快乐" || list[0].EditedAt == nil {
+		t.Fatalf("列表中应显示修改后的内容和修改时间: %+v", list)
+	}
+
+	var history struct {
+		Edits []PostEdit `json:"edits"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, path+"/history", bob, nil), &history)
+	if len(history.Edits) != 2 || history.Edits[0].Content != "新年快乐！" || history.Edits[1].Content != "新年快乐" {
+		t.Fatalf("修改记录不符: %+v", history.Edits)
+	}
+
+	// 仅自己可见的说说，其他人也看不到修改记录
+	private := ts.do(http.MethodPost, "/posts", alice, gin.H{"content": "秘密", "visibility": "private"})
+	var created struct {
+		ID int `json:"id"`
+	}
+	mustDecode(t, private, &created)
+	privatePath := "/posts/" + strconv.Itoa(created.ID)
+	expectError(t, ts.do(http.MethodGet, privatePath+"/history", bob, nil), "POST_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPut, privatePath, bob, gin.H{"content": "x"}), "POST_NOT_FOUND")
+	// 管理员可以修改任何说说，也能查看其修改记录
+	expectOK(t, ts.do(http.MethodPut, privatePath, admin, gin.H{"content": "已处理"}))
+	mustDecode(t, ts.do(http.MethodGet, privatePath+"/history", admin, nil), &history)
+	if len(history.Edits) != 1 || history.Edits[0].Content != "秘密" {
+		t.Fatalf("管理员看到的修改记录不符: %+v", history.Edits)
+	}
+	expectError(t, ts.do(http.MethodGet, privatePath+"/history", mod, nil), "POST_NOT_FOUND")
+	expectOK(t, ts.do(http.MethodDelete, privatePath, admin, nil))
+	expectError(t, ts.do(http.MethodGet, privatePath+"/history", admin, nil), "POST_NOT_FOUND")
+
+	expectOK(t, ts.do(http.MethodPost, path+"/like", bob, nil))
+	expectError(t, ts.do(http.MethodDelete, path, bob, nil), "FORBIDDEN")
+	expectOK(t, ts.do(http.MethodDelete, path, alice, nil))
+	expectError(t, ts.do(http.MethodDelete, path, alice, nil), "POST_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPut, path, alice, gin.H{"content": "x"}), "POST_NOT_FOUND")
+	expectError(t, ts.do(http.MethodGet, path+"/history", alice, nil), "POST_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPost, path+"/comments", bob, gin.H{"content": "x"}), "POST_NOT_FOUND")
+	if list := ts.listPosts(alice, "/feed").Posts; len(list) != 0 {
+		t.Fatalf("已删除的说说不应显示: %+v", list)
+	}
+	if list := ts.listPosts(bob, "/posts/liked").Posts; len(list) != 0 {
+		t.Fatalf("点赞列表不应显示已删除的说说: %+v", list)
+	}
+}
+
+// 并发修改时每次修改前的内容都应被记录，不会丢失
+func TestConcurrentPostEdits(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	postID := ts.createPost(alice, "v0")
+	path := "/posts/" + strconv.Itoa(postID)
+
+	const n = 5
+	done := make(chan apiResponse, n)
+	for i := 1; i <= n; i++ {
+		go func(i int) {
+			done <- ts.do(http.MethodPut, path, alice, gin.H{"content": "v" + strconv.Itoa(i)})
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		expectOK(t, <-done)
+	}
+
+	var history struct {
+		Edits []PostEdit `json:"edits"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, path+"/history", alice, nil), &history)
+	var post Post
+	ts.db.First(&post, postID)
+	seen := map[string]bool{post.Content: true}
+	for _, e := range history.Edits {
+		seen[e.Content] = true
+	}
+	if len(history.Edits) != n || len(seen) != n+1 {
+		t.Fatalf("修改记录丢失: 当前 %q，记录 %+v", post.Content, history.Edits)
+	}
+}
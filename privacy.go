@@ -0,0 +1,195 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 可见范围
+const (
+	VisibilityPublic  = "public"  // 所有人，包括未登录用户
+	VisibilityFriends = "friends" // 仅好友
+	VisibilityPrivate = "private" // 仅自己
+)
+
+var validVisibilities = map[string]bool{VisibilityPublic: true, VisibilityFriends: true, VisibilityPrivate: true}
+
+// 可以单独设置可见范围的个人信息字段（JSON 字段名），用户名和昵称始终公开
+var profilePrivacyFields = []string{"age", "birthday", "gender", "interests", "status"}
+
+// 未设置时的可见范围，与引入隐私设置之前的行为一致
+const defaultProfileVisibility = VisibilityPublic
+
+// 个人信息字段的可见范围，每个用户每个字段一条记录，没有记录时使用默认值
+type ProfileVisibility struct {
+	UserID     int    `gorm:"primaryKey;autoIncrement:false"`
+	Field      string `gorm:"primaryKey;type:varchar(32)"`
+	Visibility string `gorm:"type:varchar(10);not null"`
+}
+
+// 查看者与资料所有者的关系
+type viewerRelation int
+
+const (
+	viewerAnonymous viewerRelation = iota
+	viewerOther
+	viewerFriend
+	viewerOwner
+)
+
+// 该关系的查看者能否看到指定可见范围的内容
+func (r viewerRelation) canSee(visibility string) bool {
+	switch visibility {
+	case VisibilityPublic:
+		return true
+	case VisibilityFriends:
+		return r >= viewerFriend
+	default:
+		return r == viewerOwner
+	}
+}
+
+// 个人信息隐私服务
+type PrivacyService struct {
+	db      *gorm.DB
+	friends *FriendService
+}
+
+func NewPrivacyService(db *gorm.DB, friends *FriendService) *PrivacyService {
+	return &PrivacyService{db: db, friends: friends}
+}
+
+// 用户所有字段的可见范围，包含默认值
+func (s *PrivacyService) Settings(userID int) (map[string]string, error) {
+	var rows []ProfileVisibility
+	if err := s.db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	settings := make(map[string]string, len(profilePrivacyFields))
+	for _, f := range profilePrivacyFields {
+		settings[f] = defaultProfileVisibility
+	}
+	for _, r := range rows {
+		if _, ok := settings[r.Field]; ok {
+			settings[r.Field] = r.Visibility
+		}
+	}
+	return settings, nil
+}
+
+// 更新部分字段的可见范围，未传的字段保持不变
+func (s *PrivacyService) Update(userID int, changes map[string]string) error {
+	fields := make([]string, 0, len(changes))
+	for field := range changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var details []FieldError
+	rows := make([]ProfileVisibility, 0, len(changes))
+	for _, field := range fields {
+		visibility := changes[field]
+		if !isProfilePrivacyField(field) {
+			details = append(details, FieldError{Field: field, Reason: "unknown"})
+			continue
+		}
+		if !validVisibilities[visibility] {
+			details = append(details, FieldError{Field: field, Reason: "oneof"})
+			continue
+		}
+		rows = append(rows, ProfileVisibility{UserID: userID, Field: field, Visibility: visibility})
+	}
+	if len(details) > 0 {
+		return ErrInvalidRequest.WithDetails(details...)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "field"}},
+		DoUpdates: clause.AssignmentColumns([]string{"visibility"}),
+	}).Create(&rows).Error
+}
+
+func isProfilePrivacyField(field string) bool {
+	for _, f := range profilePrivacyFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// 判断查看者与资料所有者的关系，viewerID 为 0 表示未登录
+func (s *PrivacyService) relation(ownerID, viewerID int) (viewerRelation, error) {
+	switch {
+	case viewerID == 0:
+		return viewerAnonymous, nil
+	case viewerID == ownerID:
+		return viewerOwner, nil
+	}
+	friends, err := s.friends.AreFriends(ownerID, viewerID)
+	if err != nil {
+		return 0, err
+	}
+	if friends {
+		return viewerFriend, nil
+	}
+	return viewerOther, nil
+}
+
+// 按查看者的身份过滤受隐私设置控制的字段，values 为字段名到值的映射；
+// 本人查看时附带隐私设置
+func (s *PrivacyService) Filter(ownerID, viewerID int, values map[string]interface{}) (gin.H, error) {
+	rel, err := s.relation(ownerID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.Settings(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	profile := gin.H{}
+	for _, f := range profilePrivacyFields {
+		if rel.canSee(settings[f]) {
+			profile[f] = values[f]
+		}
+	}
+	if rel == viewerOwner {
+		profile["privacy"] = settings
+	}
+	return profile, nil
+}
+
+// 查询自己的隐私设置
+func (a *App) getPrivacyHandler(c *gin.Context) {
+	settings, err := a.privacy.Settings(c.MustGet("userID").(int))
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, settings, "查询成功")
+}
+
+// 修改隐私设置，请求体为 字段名 -> 可见范围
+func (a *App) updatePrivacyHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	var req map[string]string
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+	if err := a.privacy.Update(userID, req); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	settings, err := a.privacy.Settings(userID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, settings, "隐私设置已更新")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProfilePrivacy(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	_, carol := ts.signup("carol")
+	ts.befriend(alice, bob, bobID)
+	expectOK(t, ts.do(http.MethodPut, "/profile", alice, gin.H{
+		"nickName": "小爱", "age": 20, "birthday": "2004-02-10", "gender": "female", "interests": []string{"书法"}, "status": "在家过年",
+	}))
+
+	var settings map[string]string
+	mustDecode(t, ts.do(http.MethodGet, "/profile/privacy", alice, nil), &settings)
+	for _, f := range profilePrivacyFields {
+		if settings[f] != VisibilityPublic {
+			t.Fatalf("默认应公开: %+v", settings)
+		}
+	}
+	mustDecode(t, ts.do(http.MethodPut, "/profile/privacy", alice, gin.H{"age": "friends", "birthday": "private"}), &settings)
+	if settings["age"] != VisibilityFriends || settings["birthday"] != VisibilityPrivate || settings["status"] != VisibilityPublic {
+		t.Fatalf("设置未生效: %+v", settings)
+	}
+	// 再次修改同一字段
+	mustDecode(t, ts.do(http.MethodPut, "/profile/privacy", alice, gin.H{"gender": "friends"}), &settings)
+	if settings["age"] != VisibilityFriends || settings["gender"] != VisibilityFriends {
+		t.Fatalf("设置未生效: %+v", settings)
+	}
+
+	path := "/profile?userID=" + strconv.Itoa(aliceID)
+	for _, tc := range []struct {
+		name    string
+		token   string
+		visible []string
+		hidden  []string
+	}{
+		{"匿名", "", []string{"interests", "status"}, []string{"age", "birthday", "gender", "privacy"}},
+		{"陌生人", carol, []string{"interests", "status"}, []string{"age", "birthday", "gender", "privacy"}},
+		{"好友", bob, []string{"age", "gender", "interests", "status"}, []string{"birthday", "privacy"}},
+		{"本人", alice, []string{"age", "birthday", "gender", "interests", "status", "privacy"}, nil},
+	} {
+		var profile map[string]interface{}
+		mustDecode(t, ts.do(http.MethodGet, path, tc.token, nil), &profile)
+		if profile["userName"] != "alice" || profile["nickName"] != "小爱" {
+			t.Errorf("%s: 用户名和昵称应始终可见: %+v", tc.name, profile)
+		}
+		for _, f := range tc.visible {
+			if _, ok := profile[f]; !ok {
+				t.Errorf("%s: 应能看到 %s: %+v", tc.name, f, profile)
+			}
+		}
+		for _, f := range tc.hidden {
+			if _, ok := profile[f]; ok {
+				t.Errorf("%s: 不应看到 %s: %+v", tc.name, f, profile)
+			}
+		}
+	}
+
+	// 无效的令牌不会被当作匿名访问
+	expectCode(t, ts.do(http.MethodGet, path, "invalid", nil), http.StatusUnauthorized)
+
+	resp := ts.do(http.MethodPut, "/profile/privacy", alice, gin.H{"email": "public", "age": "everyone"})
+	expectError(t, resp, "INVALID_REQUEST")
+	if len(resp.Error.Details) != 2 || resp.Error.Details[0] != (FieldError{Field: "age", Reason: "oneof"}) ||
+		resp.Error.Details[1] != (FieldError{Field: "email", Reason: "unknown"}) {
+		t.Fatalf("字段详情不符: %+v", resp.Error.Details)
+	}
+	expectCode(t, ts.do(http.MethodGet, "/profile/privacy", "", nil), http.StatusUnauthorized)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const dateLayout = "2006-01-02"
+
+// 不含时间的日期，JSON 和数据库中均为 YYYY-MM-DD
+type Date struct {
+	time.Time
+}
+
+func parseDate(s string) (Date, error) {
+	t, err := time.ParseInLocation(dateLayout, s, time.UTC)
+	return Date{t}, err
+}
+
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := parseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// MySQL 返回 time.Time；SQLite 对 date 列通常也返回 time.Time，个别情况下返回字符串
+func (d *Date) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case time.Time:
+		*d = Date{time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)}
+		return nil
+	case string:
+		return d.scanString(v)
+	case []byte:
+		return d.scanString(string(v))
+	}
+	return fmt.Errorf("无法将 %T 转换为日期", value)
+}
+
+func (d *Date) scanString(s string) error {
+	if len(s) > len(dateLayout) {
+		s = s[:len(dateLayout)]
+	}
+	parsed, err := parseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// 可选的性别
+const (
+	GenderMale   = "male"
+	GenderFemale = "female"
+	GenderOther  = "other"
+)
+
+var validGenders = map[string]bool{GenderMale: true, GenderFemale: true, GenderOther: true}
+
+// 兴趣标签的数量和长度限制
+const (
+	maxInterests      = 10
+	maxInterestLength = 20
+)
+
+// 用户的兴趣标签，Position 保存用户填写的顺序
+type UserInterest struct {
+	UserID   int    `gorm:"primaryKey;autoIncrement:false"`
+	Tag      string `gorm:"primaryKey;type:varchar(80)"`
+	Position int    `gorm:"not null;default:0"`
+}
+
+// 规范化兴趣标签：去掉首尾空白和开头的 #，合并连续空白，英文转为小写，
+// 去除空标签和重复标签并保持原有顺序。标签过长时返回 reason
+func normalizeInterests(tags []string) ([]string, string) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(strings.TrimLeft(strings.TrimSpace(tag), "#＃")), " "))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxInterestLength {
+			return nil, "tag_too_long"
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxInterests {
+		return nil, "max"
+	}
+	return out, ""
+}
+
+// 校验生日：格式为 YYYY-MM-DD，不早于 1900 年且不晚于今天
+func validateBirthday(s string, now time.Time) (Date, string) {
+	d, err := parseDate(s)
+	if err != nil {
+		return Date{}, "datetime"
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if d.Year() < 1900 || d.After(today) {
+		return Date{}, "range"
+	}
+	return d, ""
+}
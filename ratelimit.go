@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	rateLimitKeyPrefix   = "ratelimit:"
+	loginFailKeyPrefix   = "login_fail:"  // 连续登录失败次数
+	loginLockKeyPrefix   = "login_lock:"  // 账户锁定标记，TTL 即剩余锁定时间
+	loginFailureRetained = 24 * time.Hour // 最后一次失败后保留失败计数的时间
+)
+
+var (
+	ErrRateLimited   = newAppError("RATE_LIMITED", http.StatusTooManyRequests, "请求过于频繁，请稍后再试", "Too many requests, please try again later")
+	ErrAccountLocked = newAppError("ACCOUNT_LOCKED", http.StatusTooManyRequests, "登录失败次数过多，账户已被暂时锁定", "Too many failed login attempts, the account is temporarily locked")
+)
+
+// 滑动窗口：有序集合中保存窗口内每次请求的时间戳（毫秒），
+// 未超限时记录本次请求并返回 0，超限时返回还需等待的毫秒数
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return 0
+end
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return tonumber(oldest[2]) + window - now
+`)
+
+// 基于 Redis 的滑动窗口限流器，多实例部署时共享计数
+type RateLimiter struct {
+	rdb *redis.Client
+}
+
+func NewRateLimiter(rdb *redis.Client) *RateLimiter {
+	return &RateLimiter{rdb: rdb}
+}
+
+// 记录一次请求，超限时返回需要等待的时间
+func (l *RateLimiter) Allow(ctx context.Context, key string, rule RateRule) (time.Duration, error) {
+	if rule.Limit <= 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	member, err := randomToken(8)
+	if err != nil {
+		return 0, err
+	}
+	wait, err := slidingWindowScript.Run(ctx, l.rdb, []string{rateLimitKeyPrefix + key},
+		now.UnixMilli(), rule.Window.Milliseconds(), rule.Limit, member).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// 连续登录失败的账户锁定
+type Lockout struct {
+	rdb *redis.Client
+	cfg LockoutConfig
+}
+
+func NewLockout(rdb *redis.Client, cfg LockoutConfig) *Lockout {
+	return &Lockout{rdb: rdb, cfg: cfg}
+}
+
+// 用户名不区分大小写，不存在的用户名同样计数，避免通过锁定行为探测账户是否存在
+func lockoutKey(userName string) string {
+	return strings.ToLower(userName)
+}
+
+// 账户剩余的锁定时间，未锁定时返回 0
+func (l *Lockout) Locked(ctx context.Context, userName string) (time.Duration, error) {
+	if l.cfg.Threshold <= 0 {
+		return 0, nil
+	}
+	ttl, err := l.rdb.PTTL(ctx, loginLockKeyPrefix+lockoutKey(userName)).Result()
+	if err != nil || ttl < 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// 记录一次登录失败，达到阈值时锁定账户，返回本次锁定的时长
+func (l *Lockout) Fail(ctx context.Context, userName string) (time.Duration, error) {
+	if l.cfg.Threshold <= 0 {
+		return 0, nil
+	}
+	key := lockoutKey(userName)
+	var failures *redis.IntCmd
+	_, err := l.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		failures = p.Incr(ctx, loginFailKeyPrefix+key)
+		p.Expire(ctx, loginFailKeyPrefix+key, loginFailureRetained)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := int(failures.Val())
+	if n%l.cfg.Threshold != 0 {
+		return 0, nil
+	}
+
+	// 第 k 次锁定时长为 Duration * 2^(k-1)
+	d := l.cfg.Duration
+	for i := 1; i < n/l.cfg.Threshold && d < l.cfg.MaxDuration; i++ {
+		d *= 2
+	}
+	d = min(d, l.cfg.MaxDuration)
+	return d, l.rdb.Set(ctx, loginLockKeyPrefix+key, 1, d).Err()
+}
+
+// 登录成功后清除失败记录
+func (l *Lockout) Reset(ctx context.Context, userName string) error {
+	key := lockoutKey(userName)
+	return l.rdb.Del(ctx, loginFailKeyPrefix+key, loginLockKeyPrefix+key).Err()
+}
+
+// 返回带 Retry-After 头的错误响应
+func responseRetryAfter(c *gin.Context, err error, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	ResponseError(c, err)
+}
+
+// 按客户端 IP 限流的中间件。Redis 不可用时放行，避免限流故障导致登录不可用
+func (a *App) rateLimit(name string, rule RateRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		wait, err := a.limiter.Allow(c.Request.Context(), name+":ip:"+c.ClientIP(), rule)
+		if err != nil {
+			log.Printf("限流检查失败: %v", err)
+		} else if wait > 0 {
+			responseRetryAfter(c, ErrRateLimited, wait)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
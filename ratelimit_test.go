@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterRateLimit(t *testing.T) {
+	ts := newTestServer(t, func(cfg *Config) {
+		cfg.RateLimit.Register = RateRule{Limit: 2, Window: time.Hour}
+	})
+	ts.signup("alice")
+	ts.signup("bob")
+
+	resp := ts.do(http.MethodPost, "/register", "", gin.H{"userName": "carol", "password": "password123"})
+	expectError(t, resp, "RATE_LIMITED")
+	if retry, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retry <= 0 || retry > 3600 {
+		t.Fatalf("Retry-After 不正确: %q", resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestLoginAccountRateLimit(t *testing.T) {
+	ts := newTestServer(t, func(cfg *Config) {
+		cfg.RateLimit.LoginAccount = RateRule{Limit: 2, Window: time.Minute}
+	})
+	ts.signup("alice") // 登录一次
+	ts.login("alice")
+
+	expectError(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "ALICE", "password": "password123"}), "RATE_LIMITED")
+	// 其他账户不受影响
+	ts.signup("bob")
+}
+
+func TestLoginLockout(t *testing.T) {
+	ts := newTestServer(t, func(cfg *Config) {
+		cfg.Lockout = LockoutConfig{Threshold: 3, Duration: time.Minute, MaxDuration: 90 * time.Second}
+	})
+	ts.signup("alice")
+	wrong := gin.H{"userName": "alice", "password": "wrong"}
+
+	fail := func() apiResponse {
+		for i := 0; i < 2; i++ {
+			expectError(t, ts.do(http.MethodPost, "/login", "", wrong), "INVALID_CREDENTIALS")
+		}
+		return ts.do(http.MethodPost, "/login", "", wrong)
+	}
+
+	resp := fail()
+	expectError(t, resp, "ACCOUNT_LOCKED")
+	if resp.Header.Get("Retry-After") != "60" {
+		t.Fatalf("首次锁定应为 60 秒，实际 %q", resp.Header.Get("Retry-After"))
+	}
+	// 锁定期间正确的密码也无法登录
+	expectError(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "alice", "password": "password123"}), "ACCOUNT_LOCKED")
+
+	// 再次锁定时长翻倍，但不超过上限
+	ts.redis.FastForward(time.Minute)
+	if resp = fail(); resp.Header.Get("Retry-After") != "90" {
+		t.Fatalf("第二次锁定应为 90 秒，实际 %q", resp.Header.Get("Retry-After"))
+	}
+
+	// 解锁后登录成功并清除失败记录
+	ts.redis.FastForward(90 * time.Second)
+	ts.login("alice")
+	expectError(t, ts.do(http.MethodPost, "/login", "", wrong), "INVALID_CREDENTIALS")
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 举报对象类型
+const (
+	ReportTargetPost     = "post"
+	ReportTargetComment  = "comment"
+	ReportTargetBlessing = "blessing"
+	ReportTargetUser     = "user"
+)
+
+// 举报处理状态
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"  // 已处理（如删除内容、封禁用户）
+	ReportDismissed = "dismissed" // 举报不成立
+)
+
+// 举报模型
+type Report struct {
+	ID         int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReporterID int        `gorm:"not null;index" json:"reporterId"`
+	TargetType string     `gorm:"type:varchar(20);not null;index:idx_reports_target" json:"targetType"`
+	TargetID   int        `gorm:"not null;index:idx_reports_target" json:"targetId"`
+	Reason     string     `gorm:"type:text;not null" json:"reason"`
+	Status     string     `gorm:"type:varchar(20);not null;default:open;index" json:"status"`
+	ResolvedBy *int       `json:"resolvedBy"`
+	ResolvedAt *time.Time `json:"resolvedAt"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"createdAt"`
+}
+
+// 举报请求结构体
+type CreateReportRequest struct {
+	TargetType string `json:"targetType" binding:"required,oneof=post comment blessing user"`
+	TargetID   int    `json:"targetId" binding:"required,min=1"`
+	Reason     string `json:"reason" binding:"required,max=1000"`
+}
+
+// 处理举报请求结构体
+type ResolveReportRequest struct {
+	Status string `json:"status" binding:"required,oneof=resolved dismissed"`
+}
+
+// 举报列表查询条件
+type ReportQuery struct {
+	Status   string `form:"status" binding:"omitempty,oneof=open resolved dismissed"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"pageSize" binding:"omitempty,min=1,max=100"`
+}
+
+var (
+	ErrReportTargetNotFound = newAppError("REPORT_TARGET_NOT_FOUND", http.StatusNotFound, "举报的内容不存在", "Reported content not found")
+	ErrReportNotFound       = newAppError("REPORT_NOT_FOUND", http.StatusNotFound, "举报不存在", "Report not found")
+	ErrReportClosed         = newAppError("REPORT_CLOSED", http.StatusConflict, "举报已处理", "Report has already been handled")
+)
+
+// 举报对象对应的模型
+var reportTargets = map[string]interface{}{
+	ReportTargetPost:     &Post{},
+	ReportTargetComment:  &Comment{},
+	ReportTargetBlessing: &Blessing{},
+	ReportTargetUser:     &User{},
+}
+
+// 举报领域服务
+type ReportService struct {
+	db       *gorm.DB
+	comments *CommentService
+}
+
+func NewReportService(db *gorm.DB, comments *CommentService) *ReportService {
+	return &ReportService{db: db, comments: comments}
+}
+
+// 提交举报
+func (s *ReportService) Create(reporterID int, req CreateReportRequest) (*Report, error) {
+	if err := s.checkTarget(reporterID, req.TargetType, req.TargetID); err != nil {
+		return nil, err
+	}
+
+	report := Report{
+		ReporterID: reporterID,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Reason:     req.Reason,
+		Status:     ReportOpen,
+	}
+	if err := s.db.Create(&report).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// 检查举报对象存在。举报人看不到的说说及其下的评论、不是自己发出或收到的祝福都与不存在一样处理
+func (s *ReportService) checkTarget(reporterID int, targetType string, targetID int) error {
+	var err error
+	switch targetType {
+	case ReportTargetPost:
+		err = checkPostVisible(s.db, reporterID, targetID)
+	case ReportTargetComment:
+		err = s.comments.checkVisible(reporterID, targetID)
+	case ReportTargetBlessing:
+		var count int64
+		if err := s.db.Model(&Blessing{}).
+			Where("id = ? AND (sender_id = ? OR receiver_id = ?)", targetID, reporterID, reporterID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrReportTargetNotFound
+		}
+	default:
+		var count int64
+		if err := s.db.Model(reportTargets[targetType]).Where("id = ?", targetID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrReportTargetNotFound
+		}
+	}
+	if errors.Is(err, ErrPostNotFound) || errors.Is(err, ErrCommentNotFound) {
+		return ErrReportTargetNotFound
+	}
+	return err
+}
+
+// 分页查询举报，最早提交的排在前面
+func (s *ReportService) List(q ReportQuery) ([]Report, int64, error) {
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.PageSize == 0 {
+		q.PageSize = 20
+	}
+
+	query := s.db.Model(&Report{})
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	reports := []Report{}
+	err := query.Order("id").Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).Find(&reports).Error
+	return reports, total, err
+}
+
+// 处理举报
+func (s *ReportService) Resolve(reportID, actorID int, status string) error {
+	var report Report
+	if err := s.db.First(&report, reportID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrReportNotFound
+		}
+		return err
+	}
+	now := time.Now()
+	res := s.db.Model(&Report{}).Where("id = ? AND status = ?", reportID, ReportOpen).
+		Updates(map[string]interface{}{"status": status, "resolved_by": actorID, "resolved_at": now})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrReportClosed
+	}
+	return nil
+}
+
+// 提交举报
+func (a *App) createReportHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	var req CreateReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	report, err := a.reports.Create(userID, req)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"id": report.ID}, "举报已提交")
+}
+
+// 查询举报
+func (a *App) adminListReportsHandler(c *gin.Context) {
+	var q ReportQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	reports, total, err := a.reports.List(q)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"reports": reports, "total": total}, "查询成功")
+}
+
+// 处理举报
+func (a *App) adminResolveReportHandler(c *gin.Context) {
+	reportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		ResponseError(c, ErrReportNotFound)
+		return
+	}
+	var req ResolveReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	if err := a.reports.Resolve(reportID, c.MustGet("userID").(int), req.Status); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "举报已处理")
+}
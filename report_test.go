@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReports(t *testing.T) {
+	ts := newTestServer(t)
+	_, mod := ts.signup("moderator1")
+	ts.setRole("moderator1", RoleModerator)
+	_, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	postID := ts.createPost(bob, "广告")
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "post", "targetId": postID, "reason": "垃圾广告"}), &created)
+	expectOK(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "user", "targetId": bobID, "reason": "骚扰"}))
+	expectError(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "post", "targetId": 999, "reason": "x"}), "REPORT_TARGET_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "planet", "targetId": 1, "reason": "x"}), "INVALID_REQUEST")
+	expectError(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "comment", "targetId": 999, "reason": "x"}), "REPORT_TARGET_NOT_FOUND")
+	expectError(t, ts.do(http.MethodGet, "/admin/reports", alice, nil), "FORBIDDEN")
+
+	var list struct {
+		Reports []Report `json:"reports"`
+		Total   int64    `json:"total"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/admin/reports?status=open", mod, nil), &list)
+	if list.Total != 2 || list.Reports[0].TargetType != ReportTargetPost || list.Reports[0].TargetID != postID {
+		t.Fatalf("举报列表不符: %+v", list)
+	}
+
+	resolvePath := "/admin/reports/" + strconv.Itoa(created.ID) + "/resolve"
+	expectOK(t, ts.do(http.MethodPost, resolvePath, mod, gin.H{"status": ReportResolved}))
+	expectError(t, ts.do(http.MethodPost, resolvePath, mod, gin.H{"status": ReportDismissed}), "REPORT_CLOSED")
+	expectError(t, ts.do(http.MethodPost, "/admin/reports/999/resolve", mod, gin.H{"status": ReportDismissed}), "REPORT_NOT_FOUND")
+
+	mustDecode(t, ts.do(http.MethodGet, "/admin/reports?status=resolved", mod, nil), &list)
+	if list.Total != 1 || list.Reports[0].ResolvedBy == nil || list.Reports[0].ResolvedAt == nil {
+		t.Fatalf("已处理的举报不符: %+v", list)
+	}
+}
+
+// 看不到的说说及其下的评论不能举报，也无法据此判断其是否存在
+func TestReportHiddenPost(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	_, bob := ts.signup("bob")
+
+	var post struct {
+		ID int `json:"id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, "/posts", bob, gin.H{"content": "秘密", "visibility": "private"}), &post)
+	var comment struct {
+		ID int `json:"id"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, "/posts/"+strconv.Itoa(post.ID)+"/comments", bob, gin.H{"content": "自言自语"}), &comment)
+
+	expectError(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "post", "targetId": post.ID, "reason": "x"}), "REPORT_TARGET_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "comment", "targetId": comment.ID, "reason": "x"}), "REPORT_TARGET_NOT_FOUND")
+	var reports int64
+	ts.db.Model(&Report{}).Count(&reports)
+	if reports != 0 {
+		t.Fatalf("不应创建举报: %d", reports)
+	}
+
+	// 作者本人可以看到，因此可以举报
+	expectOK(t, ts.do(http.MethodPost, "/reports", bob, gin.H{"targetType": "comment", "targetId": comment.ID, "reason": "x"}))
+}
+
+func TestReportOthersBlessing(t *testing.T) {
+	ts := newTestServer(t)
+	_, alice := ts.signup("alice")
+	_, bob := ts.signup("bob")
+	carolID, carol := ts.signup("carol")
+	ts.befriend(bob, carol, carolID)
+
+	expectOK(t, ts.do(http.MethodPost, "/blessings", bob, gin.H{"receiver_id": carolID, "content": "福", "font": "kaiti", "paper_style": "red"}))
+	expectOK(t, ts.do(http.MethodPost, "/blessings", bob, gin.H{"content": "草稿", "font": "kaiti", "paper_style": "red"}))
+	var sent, draft Blessing
+	ts.db.Where("receiver_id = ?", carolID).First(&sent)
+	ts.db.Where("receiver_id IS NULL").First(&draft)
+
+	// 旁人不能举报别人之间的祝福，也不能举报别人的草稿
+	expectError(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "blessing", "targetId": sent.ID, "reason": "x"}), "REPORT_TARGET_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPost, "/reports", alice, gin.H{"targetType": "blessing", "targetId": draft.ID, "reason": "x"}), "REPORT_TARGET_NOT_FOUND")
+	expectError(t, ts.do(http.MethodPost, "/reports", carol, gin.H{"targetType": "blessing", "targetId": draft.ID, "reason": "x"}), "REPORT_TARGET_NOT_FOUND")
+	var reports int64
+	ts.db.Model(&Report{}).Count(&reports)
+	if reports != 0 {
+		t.Fatalf("不应创建举报: %d", reports)
+	}
+
+	// 接收人和发送人可以举报
+	expectOK(t, ts.do(http.MethodPost, "/reports", carol, gin.H{"targetType": "blessing", "targetId": sent.ID, "reason": "x"}))
+	expectOK(t, ts.do(http.MethodPost, "/reports", bob, gin.H{"targetType": "blessing", "targetId": sent.ID, "reason": "x"}))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// 用户搜索条件
+type UserSearchQuery struct {
+	Q        string `form:"q" binding:"required,max=50"`
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"pageSize" binding:"omitempty,min=1,max=50"`
+}
+
+// 用户搜索结果，附带与当前用户的好友关系
+type UserSearchResult struct {
+	ID        int    `json:"id"`
+	UserName  string `json:"userName"`
+	NickName  string `json:"nickName"`
+	Relation  string `json:"relation"`
+	RequestID int    `json:"requestId,omitempty"` // 对方发来的待处理请求，可直接接受
+}
+
+// 模糊匹配模式：按字符（而不是字节）拆分，中间允许插入任意字符，
+// 如“张三”可以匹配“张小三”。忽略空白，全部为空白时返回空串
+func fuzzyPattern(q string) string {
+	var b strings.Builder
+	b.WriteByte('%')
+	n := 0
+	for _, r := range q {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		b.WriteString(likeEscaper.Replace(string(r)))
+		b.WriteByte('%')
+		n++
+	}
+	if n == 0 {
+		return ""
+	}
+	return b.String()
+}
+
+// 按用户名和昵称搜索用户，排除当前用户、已封禁和已注销的用户。
+// 完全匹配排在最前，其次是前缀匹配、包含匹配，最后是模糊匹配
+func (s *UserService) Search(viewerID int, q UserSearchQuery) ([]UserSearchResult, int64, error) {
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.PageSize == 0 {
+		q.PageSize = 20
+	}
+	term := strings.ToLower(strings.TrimSpace(q.Q))
+	fuzzy := fuzzyPattern(term)
+	if fuzzy == "" {
+		return []UserSearchResult{}, 0, nil
+	}
+
+	query := s.db.Model(&User{}).
+		Where("LOWER(user_name) LIKE ? ESCAPE '!' OR LOWER(nick_name) LIKE ? ESCAPE '!'", fuzzy, fuzzy).
+		Where("banned_at IS NULL AND id <> ?", viewerID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	prefix := likeEscaper.Replace(term) + "%"
+	contains := likePattern(term)
+	rank := clause.Expr{
+		SQL: `CASE WHEN LOWER(user_name) = ? OR LOWER(nick_name) = ? THEN 0
+			WHEN LOWER(user_name) LIKE ? ESCAPE '!' OR LOWER(nick_name) LIKE ? ESCAPE '!' THEN 1
+			WHEN LOWER(user_name) LIKE ? ESCAPE '!' OR LOWER(nick_name) LIKE ? ESCAPE '!' THEN 2
+			ELSE 3 END, id`,
+		Vars:               []interface{}{term, term, prefix, prefix, contains, contains},
+		WithoutParentheses: true,
+	}
+	results := []UserSearchResult{}
+	err := query.Select("id, user_name, nick_name").
+		Order(clause.OrderBy{Expression: rank}).
+		Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Scan(&results).Error
+	return results, total, err
+}
+
+// 搜索用户
+func (a *App) searchUsersHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	var q UserSearchQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+	if strings.TrimSpace(q.Q) == "" {
+		ResponseError(c, ErrInvalidRequest.WithDetails(FieldError{Field: "q", Reason: "required"}))
+		return
+	}
+
+	users, total, err := a.users.Search(userID, q)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ids := make([]int, len(users))
+	for i, u := range users {
+		ids[i] = u.ID
+	}
+	relations, err := a.friends.Relations(userID, ids)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	for i := range users {
+		rel := relations[users[i].ID]
+		users[i].Relation = rel.Status
+		users[i].RequestID = rel.RequestID
+	}
+	ResponseOK(c, gin.H{"users": users, "total": total}, "查询成功")
+}
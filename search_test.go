@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type searchResult struct {
+	Users []UserSearchResult `json:"users"`
+	Total int64              `json:"total"`
+}
+
+func (ts *testServer) searchUsers(token, q, extra string) searchResult {
+	ts.t.Helper()
+	var out searchResult
+	mustDecode(ts.t, ts.do(http.MethodGet, "/users/search?q="+url.QueryEscape(q)+extra, token, nil), &out)
+	return out
+}
+
+func searchNames(r searchResult) []string {
+	names := make([]string, len(r.Users))
+	for i, u := range r.Users {
+		names[i] = u.UserName
+	}
+	return names
+}
+
+func TestSearchUsers(t *testing.T) {
+	ts := newTestServer(t)
+	aliceID, alice := ts.signup("alice")
+	bobID, bob := ts.signup("bob")
+	bobbyID, _ := ts.signup("bobby")
+	carolID, carol := ts.signup("carol")
+	ts.signup("jimbob")
+	ts.signup("bolob")
+	bannedID, _ := ts.signup("bobban")
+	_, gone := ts.signup("bobgone")
+
+	ts.befriend(alice, bob, bobID)
+	expectOK(t, ts.do(http.MethodPost, "/friend/request", alice, gin.H{"to_id": bobbyID}))
+	expectOK(t, ts.do(http.MethodPost, "/friend/request", carol, gin.H{"to_id": aliceID}))
+	expectOK(t, ts.do(http.MethodPut, "/profile", carol, gin.H{"nickName": "Bobo"}))
+	ts.db.Model(&User{}).Where("id = ?", bannedID).Update("banned_at", time.Now())
+	expectOK(t, ts.do(http.MethodDelete, "/account", gone, nil))
+
+	// 完全匹配、前缀匹配（含昵称）、包含匹配、模糊匹配依次排列，不区分大小写
+	res := ts.searchUsers(alice, "BOB", "")
+	want := []string{"bob", "bobby", "carol", "jimbob", "bolob"}
+	if got := searchNames(res); res.Total != 5 || len(got) != len(want) {
+		t.Fatalf("搜索结果不符: total=%d %v", res.Total, got)
+	}
+	for i, name := range want {
+		if res.Users[i].UserName != name {
+			t.Fatalf("第 %d 个结果应为 %s: %v", i, name, searchNames(res))
+		}
+	}
+
+	relations := map[string]UserSearchResult{}
+	for _, u := range res.Users {
+		relations[u.UserName] = u
+	}
+	if relations["bob"].Relation != RelationFriend || relations["bobby"].Relation != RelationRequestSent ||
+		relations["jimbob"].Relation != RelationNone {
+		t.Fatalf("好友关系不符: %+v", res.Users)
+	}
+	if r := relations["carol"]; r.Relation != RelationRequestReceived || r.RequestID == 0 || r.ID != carolID {
+		t.Fatalf("应标记 carol 发来的请求: %+v", r)
+	}
+	expectOK(t, ts.do(http.MethodPost, "/friend/accept", alice, gin.H{"request_id": relations["carol"].RequestID}))
+	if r := ts.searchUsers(alice, "carol", ""); len(r.Users) != 1 || r.Users[0].Relation != RelationFriend {
+		t.Fatalf("接受后应为好友: %+v", r.Users)
+	}
+
+	// 分页
+	page := ts.searchUsers(alice, "bob", "&page=2&pageSize=2")
+	if got := searchNames(page); page.Total != 5 || len(got) != 2 || got[0] != "carol" || got[1] != "jimbob" {
+		t.Fatalf("分页结果不符: total=%d %v", page.Total, got)
+	}
+
+	// 不包含自己，通配符按字面匹配
+	if r := ts.searchUsers(alice, "alice", ""); r.Total != 0 {
+		t.Fatalf("结果不应包含自己: %v", searchNames(r))
+	}
+	if r := ts.searchUsers(alice, "b%b", ""); r.Total != 0 {
+		t.Fatalf("%% 不应作为通配符: %v", searchNames(r))
+	}
+
+	expectError(t, ts.do(http.MethodGet, "/users/search?q=", alice, nil), "INVALID_REQUEST")
+	resp := ts.do(http.MethodGet, "/users/search?q=%20%20", alice, nil)
+	expectError(t, resp, "INVALID_REQUEST")
+	if len(resp.Error.Details) != 1 || resp.Error.Details[0] != (FieldError{Field: "q", Reason: "required"}) {
+		t.Fatalf("字段详情不符: %+v", resp.Error.Details)
+	}
+	expectCode(t, ts.do(http.MethodGet, "/users/search?q=bob", "", nil), http.StatusUnauthorized)
+}
+
+func TestSearchUsersCJK(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signup("alice")
+	ts.signup("张小三")
+	ts.signup("张三丰")
+	_, li := ts.signup("lisi")
+	expectOK(t, ts.do(http.MethodPut, "/profile", li, gin.H{"nickName": "李四"}))
+
+	for q, want := range map[string][]string{
+		"张三":  {"张三丰", "张小三"}, // 前缀匹配优先于逐字模糊匹配
+		"小三":  {"张小三"},
+		"张 三": {"张小三", "张三丰"}, // 忽略空白，均为模糊匹配时按注册顺序
+		"李四":  {"lisi"},
+		"四李":  {},
+	} {
+		got := searchNames(ts.searchUsers(token, q, ""))
+		if len(got) != len(want) {
+			t.Errorf("搜索 %q 应得到 %v，实际 %v", q, want, got)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("搜索 %q 应得到 %v，实际 %v", q, want, got)
+				break
+			}
+		}
+	}
+}
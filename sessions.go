@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// 会话最近活动时间的更新间隔，避免每个请求都写 Redis
+const sessionTouchInterval = time.Minute
+
+// 保存的 User-Agent 最大长度
+const maxUserAgentLength = 255
+
+var ErrSessionNotFound = newAppError("SESSION_NOT_FOUND", http.StatusNotFound, "会话不存在或已失效", "Session not found")
+
+// 登录设备信息
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+}
+
+// 从请求中获取设备信息
+func requestMeta(c *gin.Context) SessionMeta {
+	ua := c.Request.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		ua = ua[:maxUserAgentLength]
+	}
+	return SessionMeta{UserAgent: ua, IP: c.ClientIP()}
+}
+
+// 登录会话
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"` // 是否为发起请求的会话
+}
+
+func unixField(v string) time.Time {
+	n, _ := strconv.ParseInt(v, 10, 64)
+	return time.Unix(n, 0)
+}
+
+// 最近活动时间超过更新间隔时刷新会话的活动时间和 IP，失败只记录日志
+func (a *Auth) touch(ctx context.Context, key string, lastSeen interface{}, meta SessionMeta) {
+	if v, ok := lastSeen.(string); ok && time.Since(unixField(v)) < sessionTouchInterval {
+		return
+	}
+	if err := a.rdb.HSet(ctx, key, "ip", meta.IP, "last_seen", time.Now().Unix()).Err(); err != nil {
+		log.Printf("更新会话活动时间失败: %v", err)
+	}
+}
+
+// 查询用户的全部有效会话，最近活动的排在前面，顺便清理已过期的会话ID
+func (a *Auth) Sessions(ctx context.Context, userID int) ([]Session, error) {
+	userKey := userSessionsKeyPrefix + strconv.Itoa(userID)
+	ids, err := a.rdb.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err = a.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = p.HGetAll(ctx, sessionKeyPrefix+id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []Session{}
+	var expired []interface{}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			expired = append(expired, ids[i])
+			continue
+		}
+		sessions = append(sessions, Session{
+			ID:         ids[i],
+			UserAgent:  fields["user_agent"],
+			IP:         fields["ip"],
+			CreatedAt:  unixField(fields["created_at"]),
+			LastSeenAt: unixField(fields["last_seen"]),
+		})
+	}
+	if len(expired) > 0 {
+		if err := a.rdb.SRem(ctx, userKey, expired...).Err(); err != nil {
+			log.Printf("清理过期会话失败: %v", err)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions, nil
+}
+
+// 吊销用户的指定会话，该会话的访问令牌和刷新令牌随即失效
+func (a *Auth) RevokeSession(ctx context.Context, userID int, sessionID string) error {
+	key := sessionKeyPrefix + sessionID
+	owner, err := a.rdb.HGet(ctx, key, "user_id").Int()
+	if errors.Is(err, redis.Nil) || (err == nil && owner != userID) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	_, err = a.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, key)
+		p.SRem(ctx, userSessionsKeyPrefix+strconv.Itoa(userID), sessionID)
+		return nil
+	})
+	return err
+}
+
+// 查询当前用户的登录会话
+func (a *App) listSessionsHandler(c *gin.Context) {
+	claims := currentClaims(c)
+	sessions, err := a.auth.Sessions(c.Request.Context(), claims.UserID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+	ResponseOK(c, gin.H{"sessions": sessions}, "查询成功")
+}
+
+// 退出指定会话，退出当前会话时等同于退出登录
+func (a *App) revokeSessionHandler(c *gin.Context) {
+	claims := currentClaims(c)
+	ctx := c.Request.Context()
+	var err error
+	if id := c.Param("id"); id == claims.SessionID {
+		err = a.auth.Logout(ctx, claims)
+	} else {
+		err = a.auth.RevokeSession(ctx, claims.UserID, id)
+	}
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "会话已退出")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 使用指定 User-Agent 登录
+func (ts *testServer) loginFrom(userName, userAgent string) TokenPair {
+	ts.t.Helper()
+	body := []byte(`{"userName":"` + userName + `","password":"password123"}`)
+	req, err := http.NewRequest(http.MethodPost, ts.server.URL+"/login", bytes.NewReader(body))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	var tokens TokenPair
+	mustDecode(ts.t, ts.send(req, ""), &tokens)
+	return tokens
+}
+
+func (ts *testServer) sessions(token string) []Session {
+	ts.t.Helper()
+	var out struct {
+		Sessions []Session `json:"sessions"`
+	}
+	mustDecode(ts.t, ts.do(http.MethodGet, "/sessions", token, nil), &out)
+	return out.Sessions
+}
+
+func TestListSessions(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signup("alice")
+	phone := ts.loginFrom("alice", "FestivalApp/1.0 (iPhone)")
+
+	sessions := ts.sessions(token)
+	if len(sessions) != 2 {
+		t.Fatalf("应有 2 个会话: %+v", sessions)
+	}
+	var current, other *Session
+	for i := range sessions {
+		if sessions[i].Current {
+			current = &sessions[i]
+		} else {
+			other = &sessions[i]
+		}
+	}
+	if current == nil || other == nil || other.UserAgent != "FestivalApp/1.0 (iPhone)" || other.IP == "" || other.CreatedAt.IsZero() {
+		t.Fatalf("会话信息不符: %+v", sessions)
+	}
+
+	// 超过更新间隔后访问会刷新最近活动时间
+	old := time.Now().Add(-time.Hour).Unix()
+	ts.redis.HSet(sessionKeyPrefix+other.ID, "last_seen", strconv.FormatInt(old, 10))
+	expectOK(t, ts.do(http.MethodGet, "/posts", phone.AccessToken, nil))
+	for _, s := range ts.sessions(token) {
+		if s.ID == other.ID && s.LastSeenAt.Unix() == old {
+			t.Fatal("访问后应更新最近活动时间")
+		}
+	}
+
+	// 过期的会话不再列出
+	ts.redis.Del(sessionKeyPrefix + other.ID)
+	if sessions := ts.sessions(token); len(sessions) != 1 || !sessions[0].Current {
+		t.Fatalf("过期会话应被清理: %+v", sessions)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signup("alice")
+	phone := ts.loginFrom("alice", "phone")
+	_, bob := ts.signup("bob")
+
+	var phoneID string
+	for _, s := range ts.sessions(token) {
+		if !s.Current {
+			phoneID = s.ID
+		}
+	}
+	bobID := ts.sessions(bob)[0].ID
+
+	expectError(t, ts.do(http.MethodDelete, "/sessions/"+bobID, token, nil), "SESSION_NOT_FOUND")
+	expectError(t, ts.do(http.MethodDelete, "/sessions/unknown", token, nil), "SESSION_NOT_FOUND")
+	expectOK(t, ts.do(http.MethodGet, "/posts", bob, nil))
+
+	expectOK(t, ts.do(http.MethodDelete, "/sessions/"+phoneID, token, nil))
+	expectError(t, ts.do(http.MethodGet, "/posts", phone.AccessToken, nil), "TOKEN_REVOKED")
+	expectError(t, ts.refresh(phone.RefreshToken), "REFRESH_TOKEN_INVALID")
+	expectOK(t, ts.do(http.MethodGet, "/posts", token, nil))
+
+	// 退出当前会话等同于退出登录
+	current := ts.sessions(token)[0].ID
+	expectOK(t, ts.do(http.MethodDelete, "/sessions/"+current, token, nil))
+	expectError(t, ts.do(http.MethodGet, "/sessions", token, nil), "TOKEN_REVOKED")
+}
+
+func TestRefreshKeepsSessionList(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup("alice")
+	tokens := ts.login("alice")
+
+	// 只刷新不重新登录时，会话列表也应随之续期
+	ts.redis.FastForward(ts.cfg.JWT.RefreshTTL - time.Hour)
+	var refreshed TokenPair
+	mustDecode(t, ts.refresh(tokens.RefreshToken), &refreshed)
+	ts.redis.FastForward(2 * time.Hour)
+	if sessions := ts.sessions(refreshed.AccessToken); len(sessions) != 1 {
+		t.Fatalf("刷新后的会话应仍在列表中: %+v", sessions)
+	}
+	expectOK(t, ts.do(http.MethodPost, "/auth/logout-all", refreshed.AccessToken, gin.H{}))
+	expectError(t, ts.refresh(refreshed.RefreshToken), "REFRESH_TOKEN_INVALID")
+}
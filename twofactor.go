@@ -0,0 +1,507 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// RFC 6238 TOTP 参数，与常见验证器应用的默认值一致
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	totpSkew   = 1 // 允许前后各偏差一个时间步，容忍设备时钟误差
+)
+
+const (
+	recoveryCodeCount    = 10
+	challengeMaxAttempts = 5 // 每个登录挑战最多允许输错验证码的次数
+)
+
+// Redis 键前缀
+const (
+	challengeKeyPrefix = "login_challenge:" // 登录挑战令牌哈希 -> hash: user_id、attempts
+	totpUsedKeyPrefix  = "totp_used:"       // 已使用过的验证码时间步，防止重放
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+var (
+	ErrTwoFactorEnabled     = newAppError("TWO_FACTOR_ALREADY_ENABLED", http.StatusConflict, "已启用两步验证", "Two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = newAppError("TWO_FACTOR_NOT_ENABLED", http.StatusConflict, "未启用两步验证", "Two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp    = newAppError("TWO_FACTOR_NOT_SET_UP", http.StatusConflict, "请先生成两步验证密钥", "Set up two-factor authentication first")
+	ErrTwoFactorCodeInvalid = newAppError("TWO_FACTOR_CODE_INVALID", http.StatusBadRequest, "验证码错误", "Invalid verification code")
+	ErrChallengeInvalid     = newAppError("LOGIN_CHALLENGE_INVALID", http.StatusUnauthorized, "登录验证已过期，请重新登录", "Login challenge is invalid or expired, please log in again")
+)
+
+// 两步验证恢复码，只保存哈希，每个只能使用一次
+type RecoveryCode struct {
+	ID        int        `gorm:"primaryKey;autoIncrement"`
+	UserID    int        `gorm:"not null;index"`
+	CodeHash  string     `gorm:"type:varchar(100);not null"`
+	UsedAt    *time.Time // 非空表示已使用
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
+
+// 验证码请求结构体，code 可以是验证器应用中的验证码或恢复码
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// 关闭两步验证请求结构体
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// 登录第二步请求结构体
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// 计算指定时间步的验证码
+func totpCode(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod)
+}
+
+// 校验验证码，返回匹配的时间步
+func verifyTOTP(secret, code string, now time.Time) (uint64, bool) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := uint64(now.Unix()) / uint64(totpPeriod/time.Second)
+	for delta := -totpSkew; delta <= totpSkew; delta++ {
+		counter := current + uint64(delta)
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, counter)), []byte(code)) == 1 {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// 生成恢复码，格式为 xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+// 去掉用户输入恢复码时可能带上的空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
+
+// 两步验证服务
+type TwoFactorService struct {
+	db           *gorm.DB
+	rdb          *redis.Client
+	issuer       string
+	challengeTTL time.Duration
+	now          func() time.Time
+}
+
+func NewTwoFactorService(db *gorm.DB, rdb *redis.Client, cfg TwoFactorConfig) *TwoFactorService {
+	return &TwoFactorService{db: db, rdb: rdb, issuer: cfg.Issuer, challengeTTL: cfg.ChallengeTTL, now: time.Now}
+}
+
+// 生成新的密钥，确认验证码之前不会生效。返回密钥和供验证器应用扫码的 otpauth URI
+func (s *TwoFactorService) Setup(user *User) (string, string, error) {
+	if user.TOTPEnabledAt != nil {
+		return "", "", ErrTwoFactorEnabled
+	}
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", "", err
+	}
+	secret := totpEncoding.EncodeToString(key)
+	if err := s.db.Model(&User{}).Where("id = ?", user.ID).Update("totp_secret", secret).Error; err != nil {
+		return "", "", err
+	}
+
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", s.issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", strconv.Itoa(totpDigits))
+	query.Set("period", strconv.Itoa(int(totpPeriod/time.Second)))
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + s.issuer + ":" + user.UserName,
+		RawQuery: query.Encode(),
+	}
+	return secret, uri.String(), nil
+}
+
+// 使用验证器应用中的验证码确认启用，返回新生成的恢复码
+func (s *TwoFactorService) Enable(ctx context.Context, user *User, code string) ([]string, error) {
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotSetUp
+	}
+	if err := s.checkTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", user.ID).Update("totp_enabled_at", s.now()).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// 关闭两步验证，删除密钥和全部恢复码
+func (s *TwoFactorService) Disable(ctx context.Context, user *User, code string) error {
+	if err := s.Verify(ctx, user, code); err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", user.ID).
+			Updates(map[string]interface{}{"totp_secret": "", "totp_enabled_at": nil}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// 重新生成恢复码，之前的恢复码全部作废
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, user *User, code string) ([]string, error) {
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.checkTOTP(ctx, user, code); err != nil {
+		return nil, err
+	}
+	var codes []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// 未使用的恢复码数量
+func (s *TwoFactorService) RecoveryCodesLeft(userID int) (int64, error) {
+	var n int64
+	err := s.db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&n).Error
+	return n, err
+}
+
+// 删除旧的恢复码并生成新的一组
+func replaceRecoveryCodes(tx *gorm.DB, userID int) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, recoveryCodeCount)
+	records := make([]RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := hashPassword(code)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		records[i] = RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	return codes, tx.Create(&records).Error
+}
+
+// 校验已启用两步验证的用户提交的验证码或恢复码
+func (s *TwoFactorService) Verify(ctx context.Context, user *User, code string) error {
+	if user.TOTPEnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return s.checkTOTP(ctx, user, code)
+	}
+	return s.useRecoveryCode(user.ID, code)
+}
+
+// 校验验证器应用中的验证码，同一时间步的验证码只能使用一次
+func (s *TwoFactorService) checkTOTP(ctx context.Context, user *User, code string) error {
+	counter, ok := verifyTOTP(user.TOTPSecret, strings.TrimSpace(code), s.now())
+	if !ok {
+		return ErrTwoFactorCodeInvalid
+	}
+	key := totpUsedKeyPrefix + strconv.Itoa(user.ID) + ":" + strconv.FormatUint(counter, 10)
+	fresh, err := s.rdb.SetNX(ctx, key, 1, (2*totpSkew+1)*totpPeriod).Result()
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrTwoFactorCodeInvalid
+	}
+	return nil
+}
+
+// 使用恢复码，成功后该恢复码作废
+func (s *TwoFactorService) useRecoveryCode(userID int, code string) error {
+	code = normalizeRecoveryCode(code)
+	var records []RecoveryCode
+	if err := s.db.Where("user_id = ? AND used_at IS NULL", userID).Find(&records).Error; err != nil {
+		return err
+	}
+	for _, r := range records {
+		if !checkPassword(code, r.CodeHash) {
+			continue
+		}
+		res := s.db.Model(&RecoveryCode{}).Where("id = ? AND used_at IS NULL", r.ID).Update("used_at", s.now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			break
+		}
+		return nil
+	}
+	return ErrTwoFactorCodeInvalid
+}
+
+// 密码验证通过后签发登录挑战令牌，客户端需在有效期内提交验证码
+func (s *TwoFactorService) NewChallenge(ctx context.Context, userID int) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	key := challengeKeyPrefix + hashToken(token)
+	_, err = s.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, key, "user_id", userID, "attempts", 0)
+		p.Expire(ctx, key, s.challengeTTL)
+		return nil
+	})
+	return token, err
+}
+
+// 登录挑战存在时记录一次尝试，返回用户ID和已尝试次数；不存在时返回 nil，
+// 避免挑战恰好过期后 HINCRBY 重新创建一个没有过期时间的键
+var challengeAttemptScript = redis.NewScript(`
+local key = KEYS[1]
+if redis.call('EXISTS', key) == 0 then
+	return false
+end
+local attempts = redis.call('HINCRBY', key, 'attempts', 1)
+return {tonumber(redis.call('HGET', key, 'user_id')), attempts}
+`)
+
+// 查询登录挑战对应的用户ID，并记录一次尝试，超过次数后挑战作废
+func (s *TwoFactorService) ChallengeUser(ctx context.Context, token string) (int, error) {
+	key := challengeKeyPrefix + hashToken(token)
+	res, err := challengeAttemptScript.Run(ctx, s.rdb, []string{key}).Int64Slice()
+	if errors.Is(err, redis.Nil) {
+		return 0, ErrChallengeInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	userID, attempts := int(res[0]), res[1]
+	if attempts > challengeMaxAttempts {
+		s.rdb.Del(ctx, key)
+		return 0, ErrChallengeInvalid
+	}
+	return userID, nil
+}
+
+// 登录挑战完成，令牌不能再次使用
+func (s *TwoFactorService) CompleteChallenge(ctx context.Context, token string) error {
+	n, err := s.rdb.Del(ctx, challengeKeyPrefix+hashToken(token)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrChallengeInvalid
+	}
+	return nil
+}
+
+// 查询两步验证状态
+func (a *App) twoFactorStatusHandler(c *gin.Context) {
+	user, err := a.currentUser(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	left, err := a.twoFactor.RecoveryCodesLeft(user.ID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"enabled": user.TOTPEnabledAt != nil, "recoveryCodesLeft": left}, "查询成功")
+}
+
+// 生成两步验证密钥
+func (a *App) twoFactorSetupHandler(c *gin.Context) {
+	user, err := a.currentUser(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	secret, uri, err := a.twoFactor.Setup(user)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"secret": secret, "otpauthUri": uri}, "请使用验证器应用扫码后输入验证码确认")
+}
+
+// 确认启用两步验证，恢复码只在此时返回一次
+func (a *App) twoFactorEnableHandler(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+	user, err := a.currentUser(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	codes, err := a.twoFactor.Enable(c.Request.Context(), user, req.Code)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"recoveryCodes": codes}, "已启用两步验证，请妥善保存恢复码")
+}
+
+// 关闭两步验证，需要同时提供密码和验证码
+func (a *App) twoFactorDisableHandler(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+	user, err := a.currentUser(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	if !checkPassword(req.Password, user.Password) {
+		ResponseError(c, ErrOldPasswordMismatch)
+		return
+	}
+	if err := a.twoFactor.Disable(c.Request.Context(), user, req.Code); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, nil, "已关闭两步验证")
+}
+
+// 重新生成恢复码
+func (a *App) twoFactorRecoveryCodesHandler(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+	user, err := a.currentUser(c)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	codes, err := a.twoFactor.RegenerateRecoveryCodes(c.Request.Context(), user, req.Code)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{"recoveryCodes": codes}, "恢复码已重新生成")
+}
+
+// 登录第二步：提交登录挑战令牌和验证码（或恢复码），通过后签发令牌
+func (a *App) loginTwoFactorHandler(c *gin.Context) {
+	var req LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID, err := a.twoFactor.ChallengeUser(ctx, req.ChallengeToken)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	user, err := a.users.Get(userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			err = ErrChallengeInvalid
+		}
+		ResponseError(c, err)
+		return
+	}
+	if user.BannedAt != nil {
+		ResponseError(c, ErrAccountBanned)
+		return
+	}
+	if err := a.twoFactor.Verify(ctx, user, req.Code); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	if err := a.twoFactor.CompleteChallenge(ctx, req.ChallengeToken); err != nil {
+		ResponseError(c, err)
+		return
+	}
+	a.loginSucceeded(c, user)
+}
+
+// 启用了两步验证的用户密码验证通过后，返回登录挑战而不是令牌
+func (a *App) respondLoginChallenge(c *gin.Context, user *User) {
+	token, err := a.twoFactor.NewChallenge(c.Request.Context(), user.ID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	ResponseOK(c, gin.H{
+		"twoFactorRequired": true,
+		"challengeToken":    token,
+		"expiresIn":         int64(a.cfg.TwoFactor.ChallengeTTL / time.Second),
+	}, "请输入两步验证码")
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 固定两步验证服务使用的时间，返回该时间对应的验证码生成函数
+func (ts *testServer) totpClock(secret string) (code func() string, advance func()) {
+	ts.t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	ts.app.twoFactor.now = func() time.Time { return now }
+	code = func() string { return totpCode(key, uint64(now.Unix())/30) }
+	advance = func() { now = now.Add(totpPeriod) }
+	return code, advance
+}
+
+// 为用户启用两步验证，返回验证码生成函数和恢复码
+func (ts *testServer) enableTwoFactor(token string) (code func() string, advance func(), recovery []string) {
+	ts.t.Helper()
+	var setup struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauthUri"`
+	}
+	mustDecode(ts.t, ts.do(http.MethodPost, "/2fa/setup", token, nil), &setup)
+	code, advance = ts.totpClock(setup.Secret)
+
+	var enabled struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	mustDecode(ts.t, ts.do(http.MethodPost, "/2fa/enable", token, gin.H{"code": code()}), &enabled)
+	advance()
+	return code, advance, enabled.RecoveryCodes
+}
+
+// 提交用户名密码，返回登录挑战令牌
+func (ts *testServer) loginChallenge(userName string) string {
+	ts.t.Helper()
+	var challenge struct {
+		Required bool   `json:"twoFactorRequired"`
+		Token    string `json:"challengeToken"`
+	}
+	mustDecode(ts.t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": userName, "password": "password123"}), &challenge)
+	if !challenge.Required || challenge.Token == "" {
+		ts.t.Fatalf("应返回登录挑战: %+v", challenge)
+	}
+	return challenge.Token
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 附录 B 的 SHA1 测试向量（取后 6 位）
+	key := []byte("12345678901234567890")
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		if got := totpCode(key, uint64(tc.unix)/30); got != tc.want {
+			t.Errorf("T=%d: 期望 %s，实际 %s", tc.unix, tc.want, got)
+		}
+	}
+
+	secret := totpEncoding.EncodeToString(key)
+	now := time.Unix(59, 0)
+	if _, ok := verifyTOTP(secret, "287082", now.Add(totpPeriod)); !ok {
+		t.Error("应容忍一个时间步的偏差")
+	}
+	if _, ok := verifyTOTP(secret, "287082", now.Add(2*totpPeriod)); ok {
+		t.Error("超过允许偏差的验证码不应通过")
+	}
+	if normalizeRecoveryCode(" ABCDE FGHIJ ") != "abcde-fghij" {
+		t.Error("恢复码应忽略大小写和空格")
+	}
+}
+
+func TestTwoFactorSetup(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signup("alice")
+
+	expectError(t, ts.do(http.MethodPost, "/2fa/enable", token, gin.H{"code": "123456"}), "TWO_FACTOR_NOT_SET_UP")
+
+	var setup struct {
+		Secret string `json:"secret"`
+		URI    string `json:"otpauthUri"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, "/2fa/setup", token, nil), &setup)
+	if !strings.HasPrefix(setup.URI, "otpauth://totp/festival-blessing:alice?") || !strings.Contains(setup.URI, "secret="+setup.Secret) {
+		t.Fatalf("otpauth URI 不符: %s", setup.URI)
+	}
+	code, advance := ts.totpClock(setup.Secret)
+
+	// 确认之前登录不受影响
+	ts.login("alice")
+	expectError(t, ts.do(http.MethodPost, "/2fa/enable", token, gin.H{"code": "000000"}), "TWO_FACTOR_CODE_INVALID")
+
+	var enabled struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, "/2fa/enable", token, gin.H{"code": code()}), &enabled)
+	if len(enabled.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("恢复码数量不符: %v", enabled.RecoveryCodes)
+	}
+	var hash string
+	ts.db.Model(&RecoveryCode{}).Select("code_hash").Limit(1).Scan(&hash)
+	if hash == "" || strings.Contains(strings.Join(enabled.RecoveryCodes, ","), hash) {
+		t.Fatal("恢复码应以哈希形式保存")
+	}
+	expectError(t, ts.do(http.MethodPost, "/2fa/setup", token, nil), "TWO_FACTOR_ALREADY_ENABLED")
+
+	var status struct {
+		Enabled bool  `json:"enabled"`
+		Left    int64 `json:"recoveryCodesLeft"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/2fa", token, nil), &status)
+	if !status.Enabled || status.Left != recoveryCodeCount {
+		t.Fatalf("两步验证状态不符: %+v", status)
+	}
+
+	// 重新生成后旧恢复码作废
+	advance()
+	var regenerated struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	mustDecode(t, ts.do(http.MethodPost, "/2fa/recovery-codes", token, gin.H{"code": code()}), &regenerated)
+	challenge := ts.loginChallenge("alice")
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": enabled.RecoveryCodes[0]}), "TWO_FACTOR_CODE_INVALID")
+	expectOK(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": regenerated.RecoveryCodes[0]}))
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signup("alice")
+	code, advance, recovery := ts.enableTwoFactor(token)
+
+	challenge := ts.loginChallenge("alice")
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": "bogus", "code": code()}), "LOGIN_CHALLENGE_INVALID")
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": "000000"}), "TWO_FACTOR_CODE_INVALID")
+
+	var tokens TokenPair
+	mustDecode(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": code()}), &tokens)
+	expectOK(t, ts.do(http.MethodGet, "/posts", tokens.AccessToken, nil))
+	// 挑战令牌只能使用一次
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": code()}), "LOGIN_CHALLENGE_INVALID")
+
+	// 同一时间步的验证码不能重复使用
+	challenge = ts.loginChallenge("alice")
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": code()}), "TWO_FACTOR_CODE_INVALID")
+	advance()
+	expectOK(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": code()}))
+
+	// 恢复码只能使用一次，输入时可以省略连字符
+	challenge = ts.loginChallenge("alice")
+	expectOK(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": strings.ToUpper(strings.ReplaceAll(recovery[0], "-", ""))}))
+	challenge = ts.loginChallenge("alice")
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": recovery[0]}), "TWO_FACTOR_CODE_INVALID")
+
+	var status struct {
+		Left int64 `json:"recoveryCodesLeft"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/2fa", tokens.AccessToken, nil), &status)
+	if status.Left != recoveryCodeCount-1 {
+		t.Fatalf("剩余恢复码数量不符: %d", status.Left)
+	}
+}
+
+func TestTwoFactorChallengeLimits(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signup("alice")
+	code, _, _ := ts.enableTwoFactor(token)
+
+	challenge := ts.loginChallenge("alice")
+	for i := 0; i < challengeMaxAttempts; i++ {
+		expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": "000000"}), "TWO_FACTOR_CODE_INVALID")
+	}
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": code()}), "LOGIN_CHALLENGE_INVALID")
+
+	challenge = ts.loginChallenge("alice")
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": "000000"}), "TWO_FACTOR_CODE_INVALID")
+	// 记录尝试次数不能去掉挑战的过期时间
+	for _, key := range ts.redis.Keys() {
+		if strings.HasPrefix(key, challengeKeyPrefix) && ts.redis.TTL(key) <= 0 {
+			t.Fatalf("登录挑战没有过期时间: %s", key)
+		}
+	}
+	ts.redis.FastForward(ts.cfg.TwoFactor.ChallengeTTL + time.Second)
+	expectError(t, ts.do(http.MethodPost, "/login/2fa", "", gin.H{"challengeToken": challenge, "code": code()}), "LOGIN_CHALLENGE_INVALID")
+	// 过期的挑战不会被重新创建
+	for _, key := range ts.redis.Keys() {
+		if strings.HasPrefix(key, challengeKeyPrefix) {
+			t.Fatalf("过期的登录挑战不应残留: %s", key)
+		}
+	}
+}
+
+func TestTwoFactorDisable(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signup("alice")
+	code, _, recovery := ts.enableTwoFactor(token)
+
+	expectError(t, ts.do(http.MethodPost, "/2fa/disable", token, gin.H{"password": "wrong", "code": code()}), "OLD_PASSWORD_MISMATCH")
+	expectError(t, ts.do(http.MethodPost, "/2fa/disable", token, gin.H{"password": "password123", "code": "000000"}), "TWO_FACTOR_CODE_INVALID")
+	expectOK(t, ts.do(http.MethodPost, "/2fa/disable", token, gin.H{"password": "password123", "code": recovery[1]}))
+	expectError(t, ts.do(http.MethodPost, "/2fa/disable", token, gin.H{"password": "password123", "code": code()}), "TWO_FACTOR_NOT_ENABLED")
+
+	var left int64
+	ts.db.Model(&RecoveryCode{}).Count(&left)
+	if left != 0 {
+		t.Fatalf("关闭后应删除恢复码，剩余 %d", left)
+	}
+	ts.login("alice")
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,24 +14,31 @@ import (
 
 // 用户模型
 type User struct {
-	ID        int            `gorm:"primaryKey;autoIncrement"`
-	UserName  string         `gorm:"type:varchar(100);not null;unique" json:"userName" binding:"required"`
-	Password  string         `gorm:"type:varchar(100);not null" json:"-"`
-	NickName  string         `gorm:"type:varchar(100)" json:"nickName"`
-	Age       int            `gorm:"default:0" json:"age"`
-	Birthday  string         `gorm:"type:varchar(50)" json:"birthday"`
-	Gender    string         `gorm:"type:varchar(10)" json:"gender"`
-	Interests string         `gorm:"type:text" json:"interests"`
-	Status    string         `gorm:"type:varchar(50)" json:"status"`
-	CreatedAt time.Time      `gorm:"autoCreateTime"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID            int            `gorm:"primaryKey;autoIncrement"`
+	UserName      string         `gorm:"type:varchar(100);not null;unique" json:"userName" binding:"required"`
+	Password      string         `gorm:"type:varchar(100);not null" json:"-"`
+	NickName      string         `gorm:"type:varchar(100)" json:"nickName"`
+	Age           int            `gorm:"default:0" json:"age"`
+	Birthday      *Date          `gorm:"type:date" json:"birthday"`
+	Gender        string         `gorm:"type:varchar(10)" json:"gender"` // male、female、other 或空
+	Status        string         `gorm:"type:varchar(50)" json:"status"`
+	Email         *string        `gorm:"type:varchar(255);uniqueIndex:idx_users_email" json:"-"` // 用于找回密码，不公开
+	Role          string         `gorm:"type:varchar(20);not null;default:user" json:"-"`
+	BannedAt      *time.Time     `json:"-"` // 非空表示账户已被封禁
+	BanReason     string         `gorm:"type:varchar(255)" json:"-"`
+	TOTPSecret    string         `gorm:"type:varchar(64)" json:"-"` // 两步验证密钥，未启用时为待确认的密钥或空
+	TOTPEnabledAt *time.Time     `json:"-"`                         // 非空表示已启用两步验证
+	PurgedAt      *time.Time     `json:"-"`                         // 注销到期后数据已清除的时间
+	CreatedAt     time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt     time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
 }
 
 // 注册请求结构体
 type RegisterRequest struct {
 	UserName string `json:"userName" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Email    string `json:"email" binding:"omitempty,email"` // 可选，用于找回密码
 }
 
 // 登录请求结构体
@@ -39,35 +49,255 @@ type LoginRequest struct {
 
 // 更新个人信息请求结构体
 type UpdateProfileRequest struct {
-	NickName  string `json:"nickName"`
-	Age       int    `json:"age"`
-	Birthday  string `json:"birthday"`
-	Gender    string `json:"gender"`
-	Interests string `json:"interests"`
-	Status    string `json:"status"`
+	NickName  *string  `json:"nickName" binding:"omitempty,max=32"`
+	Age       *int     `json:"age" binding:"omitempty,min=0,max=150"`
+	Birthday  *string  `json:"birthday"`  // YYYY-MM-DD，空串表示清除
+	Gender    *string  `json:"gender"`    // male、female、other，空串表示清除
+	Interests []string `json:"interests"` // 兴趣标签，传空数组表示清除
+	Status    *string  `json:"status" binding:"omitempty,max=50"`
+	Email     *string  `json:"email" binding:"omitempty,email"`
 }
 
-// 注册处理函数
-func registerHandler(c *gin.Context, db *gorm.DB) {
-	var req RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, err.Error())
-		return
-	}
+var (
+	ErrInvalidCredentials = newAppError("INVALID_CREDENTIALS", http.StatusUnauthorized, "无效的凭证", "Invalid user name or password")
+	ErrUserNameTaken      = newAppError("USER_NAME_TAKEN", http.StatusConflict, "用户名已被占用", "User name is already taken")
+	ErrUserNotFound       = newAppError("USER_NOT_FOUND", http.StatusNotFound, "用户不存在", "User not found")
+	ErrEmailTaken         = newAppError("EMAIL_TAKEN", http.StatusConflict, "邮箱已被其他账户使用", "Email is already in use")
+)
+
+// 用户领域服务
+type UserService struct {
+	db     *gorm.DB
+	policy *Policy
+}
 
-	hashedPassword, err := hashPassword(req.Password)
+func NewUserService(db *gorm.DB, policy *Policy) *UserService {
+	return &UserService{db: db, policy: policy}
+}
+
+// 注册新用户
+func (s *UserService) Register(userName, password, email string) (*User, error) {
+	if err := s.CheckUserName(userName); err != nil {
+		return nil, err
+	}
+	if err := s.policy.CheckPassword("password", password, userName); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := hashPassword(password)
 	if err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, err.Error())
-		return
+		return nil, err
 	}
 
 	user := User{
-		UserName: req.UserName,
+		UserName: userName,
 		Password: hashedPassword,
 	}
+	if email != "" {
+		email = normalizeEmail(email)
+		if err := s.checkEmailAvailable(email, 0); err != nil {
+			return nil, err
+		}
+		user.Email = &email
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		// 并发注册时由唯一索引兜底
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			if err := s.CheckUserName(userName); err != nil {
+				return nil, err
+			}
+			return nil, ErrEmailTaken
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 检查用户名是否符合策略且未被占用
+func (s *UserService) CheckUserName(userName string) error {
+	if err := s.policy.CheckUserName(userName); err != nil {
+		return err
+	}
+	// 已注销的用户名同样受唯一索引约束
+	var count int64
+	if err := s.db.Unscoped().Model(&User{}).Where("user_name = ?", userName).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserNameTaken
+	}
+	return nil
+}
+
+// 校验用户名和密码
+func (s *UserService) Authenticate(userName, password string) (*User, error) {
+	var user User
+	if err := s.db.Unscoped().Where("user_name = ?", userName).First(&user).Error; err != nil {
+		checkPassword(password, dummyPasswordHash) // 防止通过响应时间判断用户名是否存在
+		return nil, ErrInvalidCredentials
+	}
+	if !checkPassword(password, user.Password) {
+		return nil, ErrInvalidCredentials
+	}
+	// 注销等待期内的账户需要先恢复
+	if user.DeletedAt.Valid {
+		return nil, ErrAccountPendingDeletion
+	}
+	// 密码正确后才提示封禁，避免泄露账户状态
+	if user.BannedAt != nil {
+		return nil, ErrAccountBanned
+	}
+	return &user, nil
+}
+
+func (s *UserService) Get(userID int) (*User, error) {
+	var user User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 邮箱不区分大小写
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// 检查邮箱是否已被其他用户（包括已注销的用户）使用
+func (s *UserService) checkEmailAvailable(email string, exceptUserID int) error {
+	var count int64
+	if err := s.db.Unscoped().Model(&User{}).Where("email = ? AND id <> ?", email, exceptUserID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// 按邮箱查找用户
+func (s *UserService) FindByEmail(email string) (*User, error) {
+	var user User
+	if err := s.db.Where("email = ?", normalizeEmail(email)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 更新密码，新密码同样需要符合密码策略
+// 检查新密码是否符合密码策略
+func (s *UserService) CheckNewPassword(userID int, password string) error {
+	user, err := s.Get(userID)
+	if err != nil {
+		return err
+	}
+	return s.policy.CheckPassword("newPassword", password, user.UserName)
+}
+
+func (s *UserService) SetPassword(userID int, password string) error {
+	if err := s.CheckNewPassword(userID, password); err != nil {
+		return err
+	}
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(&User{}).Where("id = ?", userID).Update("password", hashedPassword).Error
+}
+
+// 更新个人信息，只修改请求中出现的字段
+func (s *UserService) UpdateProfile(userID int, req UpdateProfileRequest) error {
+	updates := map[string]interface{}{}
+	var details []FieldError
+	if req.NickName != nil {
+		updates["nick_name"] = strings.TrimSpace(*req.NickName)
+	}
+	if req.Age != nil {
+		updates["age"] = *req.Age
+	}
+	if req.Birthday != nil {
+		if *req.Birthday == "" {
+			updates["birthday"] = nil
+		} else if d, reason := validateBirthday(*req.Birthday, time.Now()); reason != "" {
+			details = append(details, FieldError{Field: "birthday", Reason: reason})
+		} else {
+			updates["birthday"] = d
+		}
+	}
+	if req.Gender != nil {
+		if *req.Gender != "" && !validGenders[*req.Gender] {
+			details = append(details, FieldError{Field: "gender", Reason: "oneof"})
+		} else {
+			updates["gender"] = *req.Gender
+		}
+	}
+	var interests []string
+	if req.Interests != nil {
+		var reason string
+		if interests, reason = normalizeInterests(req.Interests); reason != "" {
+			details = append(details, FieldError{Field: "interests", Reason: reason})
+		}
+	}
+	if req.Status != nil {
+		updates["status"] = strings.TrimSpace(*req.Status)
+	}
+	if len(details) > 0 {
+		return ErrInvalidRequest.WithDetails(details...)
+	}
+	if req.Email != nil {
+		email := normalizeEmail(*req.Email)
+		if err := s.checkEmailAvailable(email, userID); err != nil {
+			return err
+		}
+		updates["email"] = email
+	}
 
-	if err := db.Create(&user).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, err.Error())
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Interests == nil {
+			return nil
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&UserInterest{}).Error; err != nil {
+			return err
+		}
+		if len(interests) == 0 {
+			return nil
+		}
+		rows := make([]UserInterest, len(interests))
+		for i, tag := range interests {
+			rows[i] = UserInterest{UserID: userID, Tag: tag, Position: i}
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// 用户的兴趣标签，按填写顺序排列
+func (s *UserService) Interests(userID int) ([]string, error) {
+	tags := []string{}
+	err := s.db.Model(&UserInterest{}).Where("user_id = ?", userID).Order("position").Pluck("tag", &tags).Error
+	return tags, err
+}
+
+// 注册处理函数
+func (a *App) registerHandler(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ResponseError(c, invalidRequest(err))
+		return
+	}
+
+	user, err := a.users.Register(req.UserName, req.Password, req.Email)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 	ResponseOK(c, gin.H{
@@ -76,33 +306,84 @@ func registerHandler(c *gin.Context, db *gorm.DB) {
 	}, "注册成功")
 }
 
+// 查询用户名是否可用，不可用时 reason 为对应的错误码
+func (a *App) checkUserNameHandler(c *gin.Context) {
+	userName := c.Query("userName")
+	err := a.users.CheckUserName(userName)
+	var appErr *AppError
+	if err != nil && !errors.As(err, &appErr) {
+		ResponseError(c, err)
+		return
+	}
+
+	result := gin.H{"userName": userName, "available": err == nil}
+	if appErr != nil {
+		result["reason"] = appErr.Code
+		result["details"] = appErr.Details
+	}
+	ResponseOK(c, result, "查询成功")
+}
+
 // 登录处理函数
-func loginHandler(c *gin.Context, db *gorm.DB) {
+func (a *App) loginHandler(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, err.Error())
+		ResponseError(c, invalidRequest(err))
 		return
 	}
 
-	var user User
-	if err := db.Where("user_name = ?", req.UserName).First(&user).Error; err != nil {
-		ResponseFAIL(c, http.StatusUnauthorized, "无效的凭证")
+	ctx := c.Request.Context()
+	if wait, err := a.limiter.Allow(ctx, "login:account:"+lockoutKey(req.UserName), a.cfg.RateLimit.LoginAccount); err != nil {
+		log.Printf("限流检查失败: %v", err)
+	} else if wait > 0 {
+		responseRetryAfter(c, ErrRateLimited, wait)
+		return
+	}
+	if wait, err := a.lockout.Locked(ctx, req.UserName); err != nil {
+		log.Printf("查询账户锁定状态失败: %v", err)
+	} else if wait > 0 {
+		responseRetryAfter(c, ErrAccountLocked, wait)
 		return
 	}
 
-	if !checkPassword(req.Password, user.Password) {
-		ResponseFAIL(c, http.StatusUnauthorized, "无效的凭证")
+	user, err := a.users.Authenticate(req.UserName, req.Password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		wait, lockErr := a.lockout.Fail(ctx, req.UserName)
+		switch {
+		case lockErr != nil:
+			log.Printf("记录登录失败次数失败: %v", lockErr)
+		case wait > 0:
+			responseRetryAfter(c, ErrAccountLocked, wait)
+			return
+		}
+	}
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
+	if err := a.lockout.Reset(ctx, req.UserName); err != nil {
+		log.Printf("清除登录失败记录失败: %v", err)
+	}
 
-	token, err := generateToken(user.ID)
+	if user.TOTPEnabledAt != nil {
+		a.respondLoginChallenge(c, user)
+		return
+	}
+	a.loginSucceeded(c, user)
+}
+
+// 签发令牌并返回登录结果
+func (a *App) loginSucceeded(c *gin.Context, user *User) {
+	tokens, err := a.auth.Login(c.Request.Context(), user.ID, requestMeta(c))
 	if err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "令牌生成失败")
+		ResponseError(c, err)
 		return
 	}
 
 	ResponseOK(c, gin.H{
-		"token": token,
+		"token":        tokens.AccessToken,
+		"refreshToken": tokens.RefreshToken,
+		"expiresIn":    tokens.ExpiresIn,
 		"user": gin.H{
 			"id":       user.ID,
 			"userName": user.UserName,
@@ -110,58 +391,76 @@ func loginHandler(c *gin.Context, db *gorm.DB) {
 	}, "登录成功")
 }
 
-func updateProfileHandler(c *gin.Context, db *gorm.DB) {
+// 按查看者身份组装个人信息，viewerID 为 0 表示未登录
+func (a *App) profileView(user *User, viewerID int) (gin.H, error) {
+	interests, err := a.users.Interests(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	avatarURL, err := a.avatars.URL(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := a.privacy.Filter(user.ID, viewerID, map[string]interface{}{
+		"age":       user.Age,
+		"birthday":  user.Birthday,
+		"gender":    user.Gender,
+		"interests": interests,
+		"status":    user.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	profile["id"] = user.ID
+	profile["userName"] = user.UserName
+	profile["nickName"] = user.NickName
+	profile["avatarUrl"] = avatarURL
+	if viewerID == user.ID {
+		profile["email"] = user.Email
+	}
+	return profile, nil
+}
+
+// 更新个人信息（PATCH 语义，PUT 同样只修改传入的字段），返回更新后的完整个人信息
+func (a *App) updateProfileHandler(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
 
 	var req UpdateProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ResponseFAIL(c, http.StatusBadRequest, err.Error())
+		ResponseError(c, invalidRequest(err))
 		return
 	}
 
-	updates := map[string]interface{}{
-		"nick_name": req.NickName,
-		"age":       req.Age,
-		"gender":    req.Gender,
-		"interests": req.Interests,
-		"status":    req.Status,
-		"birthday":  req.Birthday,
+	if err := a.users.UpdateProfile(userID, req); err != nil {
+		ResponseError(c, err)
+		return
 	}
-
-	if err := db.Model(&User{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "更新失败")
+	user, err := a.users.Get(userID)
+	if err != nil {
+		ResponseError(c, err)
+		return
+	}
+	profile, err := a.profileView(user, userID)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
-	ResponseOK(c, updates, "个人信息更新成功")
+	ResponseOK(c, profile, "个人信息更新成功")
 }
 
-func getProfileHandler(c *gin.Context, db *gorm.DB) {
+// 查询个人信息，按隐私设置只返回查看者可见的字段；未登录时按匿名用户处理
+func (a *App) getProfileHandler(c *gin.Context) {
 	userID, _ := strconv.Atoi(c.Query("userID"))
-	var user User
-	if err := db.First(&user, userID).Error; err != nil {
-		ResponseFAIL(c, http.StatusNotFound, "用户不存在")
+	user, err := a.users.Get(userID)
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
 
-	response := gin.H{
-		"userName":  user.UserName,
-		"nickName":  user.NickName,
-		"age":       user.Age,
-		"gender":    user.Gender,
-		"interests": user.Interests,
-		"status":    user.Status,
-		"birthday":  user.Birthday,
-	}
-	ResponseOK(c, response, "获取成功")
-}
-
-// 删除账户处理函数
-func deleteAccountHandler(c *gin.Context, db *gorm.DB) {
-	userID := c.MustGet("userID").(int)
-
-	if err := db.Delete(&User{}, userID).Error; err != nil {
-		ResponseFAIL(c, http.StatusInternalServerError, "删除失败")
+	response, err := a.profileView(user, c.GetInt("userID"))
+	if err != nil {
+		ResponseError(c, err)
 		return
 	}
-	ResponseOK(c, nil, "账户删除成功")
+	ResponseOK(c, response, "获取成功")
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	ts := newTestServer(t)
+
+	userID, token := ts.signup("alice")
+	if userID == 0 || token == "" {
+		t.Fatalf("登录结果不完整: id=%d token=%q", userID, token)
+	}
+
+	expectError(t, ts.do(http.MethodPost, "/register", "", gin.H{"userName": "alice", "password": "another"}), "USER_NAME_TAKEN")
+	expectCode(t, ts.do(http.MethodPost, "/register", "", gin.H{"userName": "bob"}), http.StatusBadRequest)
+	expectCode(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "alice", "password": "wrong"}), http.StatusUnauthorized)
+	expectCode(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "nobody", "password": "password123"}), http.StatusUnauthorized)
+}
+
+// 用户不存在时比对的哈希必须有效且代价与真实密码相同，否则响应时间会暴露用户名是否存在
+func TestDummyPasswordHash(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil || cost != bcrypt.DefaultCost {
+		t.Fatalf("哈希无效或代价不符: cost=%d err=%v", cost, err)
+	}
+	hash, err := hashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := bcrypt.Cost([]byte(hash)); got != cost {
+		t.Fatalf("代价不符: %d != %d", got, cost)
+	}
+}
+
+func TestRegisterPolicy(t *testing.T) {
+	ts := newTestServer(t)
+	ts.signup("alice")
+
+	resp := ts.do(http.MethodPost, "/register", "", gin.H{"userName": "bob smith", "password": "password123"})
+	expectError(t, resp, "USER_NAME_INVALID")
+	if resp.Error.Details[0] != (FieldError{Field: "userName", Reason: "charset"}) {
+		t.Fatalf("字段详情不符: %+v", resp.Error.Details)
+	}
+	expectError(t, ts.do(http.MethodPost, "/register", "", gin.H{"userName": "root", "password": "password123"}), "USER_NAME_INVALID")
+	expectError(t, ts.do(http.MethodPost, "/register", "", gin.H{"userName": "bob", "password": "1"}), "PASSWORD_TOO_WEAK")
+
+	var check struct {
+		Available bool         `json:"available"`
+		Reason    string       `json:"reason"`
+		Details   []FieldError `json:"details"`
+	}
+	mustDecode(t, ts.do(http.MethodGet, "/register/check?userName=bob", "", nil), &check)
+	if !check.Available || check.Reason != "" {
+		t.Fatalf("bob 应可用: %+v", check)
+	}
+	for name, reason := range map[string]string{"alice": "USER_NAME_TAKEN", "Admin": "USER_NAME_INVALID", "": "USER_NAME_INVALID"} {
+		check.Reason = ""
+		mustDecode(t, ts.do(http.MethodGet, "/register/check?userName="+name, "", nil), &check)
+		if check.Available || check.Reason != reason {
+			t.Fatalf("%q 应不可用，原因 %s，实际 %+v", name, reason, check)
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	ts := newTestServer(t)
+	userID, token := ts.signup("alice")
+
+	expectOK(t, ts.do(http.MethodPut, "/profile", token, gin.H{
+		"nickName":  "小爱",
+		"age":       20,
+		"birthday":  "2004-02-10",
+		"gender":    "female",
+		"interests": []string{"reading"},
+		"status":    "happy",
+	}))
+
+	type profileView struct {
+		UserName  string   `json:"userName"`
+		NickName  string   `json:"nickName"`
+		Age       int      `json:"age"`
+		Birthday  *Date    `json:"birthday"`
+		Gender    string   `json:"gender"`
+		Interests []string `json:"interests"`
+		Status    string   `json:"status"`
+		AvatarURL string   `json:"avatarUrl"`
+		Email     *string  `json:"email"`
+	}
+	var profile profileView
+	mustDecode(t, ts.do(http.MethodGet, "/profile?userID="+strconv.Itoa(userID), "", nil), &profile)
+	if profile.UserName != "alice" || profile.NickName != "小爱" || profile.Age != 20 || profile.Email != nil {
+		t.Fatalf("个人信息不符: %+v", profile)
+	}
+
+	// 只修改传入的字段，响应为更新后的完整个人信息
+	ts.uploadAvatar(token)
+	var updated profileView
+	mustDecode(t, ts.do(http.MethodPatch, "/profile", token, gin.H{
+		"nickName":  "阿爱",
+		"interests": []string{" #Reading ", "书法", "reading", "Rock  Music"},
+	}), &updated)
+	if updated.NickName != "阿爱" || updated.Age != 20 || updated.Birthday == nil || updated.Birthday.String() != "2004-02-10" ||
+		updated.Gender != "female" || updated.Status != "happy" || updated.AvatarURL != "/avatar/"+strconv.Itoa(userID)+".png" {
+		t.Fatalf("未传的字段不应改变: %+v", updated)
+	}
+	if len(updated.Interests) != 3 || updated.Interests[0] != "reading" || updated.Interests[1] != "书法" || updated.Interests[2] != "rock music" {
+		t.Fatalf("兴趣标签未规范化: %q", updated.Interests)
+	}
+
+	// 空串和空数组表示清除
+	mustDecode(t, ts.do(http.MethodPatch, "/profile", token, gin.H{"birthday": "", "gender": "", "interests": []string{}}), &updated)
+	if updated.Birthday != nil || updated.Gender != "" || len(updated.Interests) != 0 || updated.NickName != "阿爱" {
+		t.Fatalf("字段未清除: %+v", updated)
+	}
+
+	tooMany := make([]string, maxInterests+1)
+	for i := range tooMany {
+		tooMany[i] = "tag" + strconv.Itoa(i)
+	}
+	for _, tc := range []struct {
+		body  gin.H
+		field string
+		tag   string
+	}{
+		{gin.H{"birthday": "2004-02-30"}, "birthday", "datetime"},
+		{gin.H{"birthday": "10/02/2004"}, "birthday", "datetime"},
+		{gin.H{"birthday": "2999-01-01"}, "birthday", "range"},
+		{gin.H{"gender": "robot"}, "gender", "oneof"},
+		{gin.H{"interests": tooMany}, "interests", "max"},
+		{gin.H{"interests": []string{"这是一个非常非常非常非常非常非常长的兴趣标签"}}, "interests", "tag_too_long"},
+		{gin.H{"age": 200}, "age", "max"},
+	} {
+		resp := ts.do(http.MethodPatch, "/profile", token, tc.body)
+		expectError(t, resp, "INVALID_REQUEST")
+		if len(resp.Error.Details) != 1 || resp.Error.Details[0] != (FieldError{Field: tc.field, Reason: tc.tag}) {
+			t.Errorf("%v: 字段详情不符: %+v", tc.body, resp.Error.Details)
+		}
+	}
+
+	expectError(t, ts.do(http.MethodGet, "/profile?userID=999", "", nil), "USER_NOT_FOUND")
+}
+
+func TestDeleteAccount(t *testing.T) {
+	ts := newTestServer(t)
+	_, token := ts.signup("alice")
+
+	expectOK(t, ts.do(http.MethodDelete, "/account", token, nil))
+	expectError(t, ts.do(http.MethodGet, "/posts", token, nil), "TOKEN_REVOKED")
+	expectError(t, ts.do(http.MethodPost, "/login", "", gin.H{"userName": "alice", "password": "password123"}), "ACCOUNT_PENDING_DELETION")
+}
+
+// 跨域客户端通过 PATCH 更新个人信息时预检请求应通过
+func TestProfilePatchPreflight(t *testing.T) {
+	ts := newTestServer(t, func(cfg *Config) { cfg.CORS.AllowOrigins = []string{"https://app.example.com"} })
+	req, _ := http.NewRequest(http.MethodOptions, ts.server.URL+"/profile", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if allowed := resp.Header.Get("Access-Control-Allow-Methods"); resp.StatusCode >= 300 || !strings.Contains(allowed, http.MethodPatch) {
+		t.Fatalf("预检请求未通过: %d %q", resp.StatusCode, allowed)
+	}
+}
@@ -3,9 +3,13 @@ package main
 import (
 	"encoding/json"
 	"github.com/gin-gonic/gin"
+	"log"
 	"net/http"
 )
 
+// 上下文中记录是否返回真实 HTTP 状态码的键
+const realStatusKey = "realStatus"
+
 func DeserializeJSON(c *gin.Context) (map[string]interface{}, error) {
 	b, err := c.GetRawData()
 	if err != nil {
@@ -26,10 +30,43 @@ func ResponseOK(c *gin.Context, data interface{}, msg string) {
 	})
 }
 
-func ResponseFAIL(c *gin.Context, status int, msg string) {
-	c.JSON(http.StatusOK, gin.H{
-		"code": status,
+// 返回错误响应。默认 HTTP 状态恒为 200、真实状态放在 code 字段中以兼容旧客户端；
+// 开启 real_status_codes 后 HTTP 状态与 code 一致
+func ResponseError(c *gin.Context, err error) {
+	appErr := asAppError(err)
+	if appErr.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, appErr)
+	}
+
+	status := http.StatusOK
+	if c.GetBool(realStatusKey) {
+		status = appErr.Status
+	}
+	details := appErr.Details
+	if details == nil {
+		details = []FieldError{}
+	}
+	c.JSON(status, gin.H{
+		"code": appErr.Status,
 		"data": nil,
-		"msg":  msg,
+		"msg":  appErr.Localize(preferredLanguage(c.GetHeader("Accept-Language"))),
+		"error": gin.H{
+			"code":    appErr.Code,
+			"details": details,
+		},
 	})
 }
+
+// 返回错误响应并中止后续处理，用于中间件
+func AbortWithError(c *gin.Context, err error) {
+	ResponseError(c, err)
+	c.Abort()
+}
+
+// 设置当前请求的错误响应模式
+func errorModeMiddleware(realStatus bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(realStatusKey, realStatus)
+		c.Next()
+	}
+}